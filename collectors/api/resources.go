@@ -2,18 +2,17 @@ package api
 
 import (
 	"net/http"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/kubernetes"
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
 	"log"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/pkg/api/v1"
-	"strings"
 	"encoding/json"
 )
 type Container struct {
 	Id string 	`json:"id"`
 	Name string `json:"name"`
 	Status string `json:"status"`
+	Runtime string `json:"runtime"`
 }
 type Pod struct {
 	Name string	`json:"name"`
@@ -30,36 +29,36 @@ type Resource struct {
 }
 
 func GetContainerList(w http.ResponseWriter, r *http.Request) {
-	master_ip:=r.URL.Query().Get("master_ip")
-	aport:=r.URL.Query().Get("api_port")
-	if master_ip=="" ||aport==""  {
-		http.Error(w,"invalid request parameter",400)
-		return
-	}
-	endpoint := master_ip + ":" + aport
-	config := &rest.Config{
-		Host: "http://" + endpoint,
-	}
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Printf("get clientset error: %s", err.Error())
-		http.Error(w,"create clientset error ",500)
-		return
+	var params map[string]string
+	if target:=r.URL.Query().Get("target");target!="" {
+		params = config.GetMonitorInfo(target).Params_maps
+	}else {
+		master_ip:=r.URL.Query().Get("master_ip")
+		aport:=r.URL.Query().Get("api_port")
+		if master_ip=="" ||aport==""  {
+			http.Error(w,"invalid request parameter",400)
+			return
+		}
+		params = map[string]string{
+			kube.ParamMasterIP:master_ip,
+			kube.ParamAPIPort:aport,
+			kube.ParamAPIScheme:r.URL.Query().Get("api_scheme"),
+		}
 	}
-	nodelist, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+	clustercache, err := kube.Caches.Get(params)
 	if err != nil {
-		log.Printf("get node list error: %s", err.Error())
+		log.Printf("get cluster cache error: %s", err.Error())
 		http.Error(w,"cannot get nodes",404)
 		return
 	}
 	var nodes=[]Node{}
-	for _,v :=range nodelist.Items{
+	for _,v :=range clustercache.Nodes(){
 		nodeip := getNodeIP(v)
 		if nodeip=="" {
 			log.Printf("cannot get IP of the node:%s",v.Name)
 			continue
 		}
-		pods:=getPodInfo(clientset,nodeip)
+		pods:=getPodInfo(clustercache,v.Name)
 		node:=Node{nodeip,v.Name,pods,}
 		nodes=append(nodes,node)
 	}
@@ -67,7 +66,7 @@ func GetContainerList(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type","application/json")
 	json.NewEncoder(w).Encode(resource)
 }
-func getNodeIP(node v1.Node) string {
+func getNodeIP(node *v1.Node) string {
 	nodeaddresss:=node.Status.Addresses
 	for _,v :=range nodeaddresss{
 		if v.Type =="InternalIP" {
@@ -76,40 +75,33 @@ func getNodeIP(node v1.Node) string {
 	}
 	return ""
 }
-func getPodInfo(clientset *kubernetes.Clientset,nodeip string) []Pod{
+func getPodInfo(clustercache *kube.ClusterCache,nodename string) []Pod{
 	var newPods []Pod
-	pods,err:=clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+	pods,err:=clustercache.PodsOnNode(nodename)
 	if err!=nil {
 		log.Printf("get pods error:%s",err.Error())
 		return nil
 	}
-	for _,p:=range pods.Items{
-		if p.Status.HostIP == nodeip {
-			podname :=p.Name
-			containerinfo := p.Status.ContainerStatuses
-			var cons =[]Container{}
-			for _,c:=range containerinfo{
-				if c.ContainerID =="" {
-					log.Printf("no container id in pod:%s",p.Name)
-					continue
-				}
-				containerid:= convertContainerID(c.ContainerID)
-				containername:=c.Name
-				containerstatus:=getContainerStatus(c.State)
-				con:=Container{containerid,containername,containerstatus,}
-				cons=append(cons,con)
+	for _,p:=range pods{
+		podname :=p.Name
+		containerinfo := p.Status.ContainerStatuses
+		var cons =[]Container{}
+		for _,c:=range containerinfo{
+			if c.ContainerID =="" {
+				log.Printf("no container id in pod:%s",p.Name)
+				continue
 			}
-			pod:=Pod{podname,p.Namespace,cons,}
-			newPods=append(newPods,pod)
+			runtime,containerid:= kube.SplitContainerID(c.ContainerID)
+			containername:=c.Name
+			containerstatus:=getContainerStatus(c.State)
+			con:=Container{containerid,containername,containerstatus,runtime,}
+			cons=append(cons,con)
 		}
+		pod:=Pod{podname,p.Namespace,cons,}
+		newPods=append(newPods,pod)
 	}
 	return newPods
 }
-func convertContainerID(id string) string {
-	s:=strings.Split(id,"://")
-	shortid:=s[1]
-	return shortid
-}
 func getContainerStatus(state v1.ContainerState) string {
 	var conatinerstate="3"
 	if state.Waiting !=nil {
@@ -0,0 +1,78 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2026-10-18 is a Sunday.
+	base := time.Date(2026, 10, 18, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", base, time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC), time.Date(2026, 10, 18, 10, 45, 0, 0, time.UTC)},
+		{"10-20/5 * * * *", base, time.Date(2026, 10, 18, 10, 20, 0, 0, time.UTC)},
+		{"10-20/5 * * * *", time.Date(2026, 10, 18, 10, 20, 0, 0, time.UTC), time.Date(2026, 10, 18, 11, 10, 0, 0, time.UTC)},
+		{"7/20 * * * *", base, time.Date(2026, 10, 18, 10, 27, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", base, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", time.Date(2026, 10, 23, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 26, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", base, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// 7 is Sunday like 0.
+		{"5 4 * * 7", base, time.Date(2026, 10, 25, 4, 5, 0, 0, time.UTC)},
+		{"5 4 * * 0", base, time.Date(2026, 10, 25, 4, 5, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matching is enough.
+		{"0 0 1,15 * 5", base, time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * 5", time.Date(2026, 10, 30, 12, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		// Only day of month restricted.
+		{"0 0 13 * *", base, time.Date(2026, 11, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * ?", base, time.Date(2026, 11, 13, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", base, time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)},
+		{"@daily", base, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", base, time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", base, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", base, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Month rollover skips months without the day.
+		{"0 0 31 * *", time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC), time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)},
+		// Year rollover.
+		{"59 23 31 12 *", time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC), time.Date(2027, 12, 31, 23, 59, 0, 0, time.UTC)},
+		{"0 0 29 2 *", base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Impossible dates never fire.
+		{"0 0 30 2 *", base, time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := parseCronSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("parseCronSchedule(%q) error: %s", tt.spec, err)
+			continue
+		}
+		if got := s.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q next after %s = %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"bad",
+		"* * * *",
+		"* * * * * *",
+		"61 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every 5m",
+	} {
+		if _, err := parseCronSchedule(spec); err == nil {
+			t.Errorf("parseCronSchedule(%q) succeeded, want error", spec)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package collectors
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+// HostCollector reads the procfs of the host the exporter runs on, mounted
+// at ProcPath. It is meant for the exporter running as a DaemonSet, where it
+// does not depend on cadvisor or the kubelet.
+type HostCollector struct {
+	ProcPath string
+}
+
+func (c HostCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+// hostSubCollector reads one procfs file. Files that only exist with some
+// kernel modules loaded (md, xfs, nfs) are skipped when missing.
+type hostSubCollector struct {
+	name     string
+	optional string
+	collect  func(ch chan<- prometheus.Metric, fs procfs.FS) error
+}
+
+var hostSubCollectors = []hostSubCollector{
+	{"cpu", "", collectHostCPU},
+	{"meminfo", "", collectHostMemory},
+	{"netdev", "", collectHostNetDev},
+	{"mountstats", "", collectHostMountStats},
+	{"mdstat", "mdstat", collectHostMDStat},
+	{"xfs", "fs/xfs/stat", collectHostXFS},
+	{"nfs", "net/rpc/nfs", collectHostNFS},
+	{"buddyinfo", "buddyinfo", collectHostBuddyInfo},
+}
+
+var (
+	host_monitorstatus            = prometheus.NewDesc("host_monitorstatus", "host procfs monitor status", nil, nil)
+	host_scrape_collector_success = prometheus.NewDesc("host_scrape_collector_success",
+		"whether a host procfs file could be read", []string{"collector"}, nil)
+
+	host_boot_time_seconds      = prometheus.NewDesc("host_boot_time_seconds", "host boot time since unix epoch in seconds", nil, nil)
+	host_cpu_seconds_total      = prometheus.NewDesc("host_cpu_seconds_total", "seconds the cpus spent in each mode", []string{"cpu", "mode"}, nil)
+	host_context_switches_total = prometheus.NewDesc("host_context_switches_total", "total number of context switches", nil, nil)
+	host_forks_total            = prometheus.NewDesc("host_forks_total", "total number of forks", nil, nil)
+	host_intr_total             = prometheus.NewDesc("host_intr_total", "total number of interrupts serviced", nil, nil)
+	host_procs_running          = prometheus.NewDesc("host_procs_running", "number of processes in runnable state", nil, nil)
+	host_procs_blocked          = prometheus.NewDesc("host_procs_blocked", "number of processes blocked waiting for I/O", nil, nil)
+
+	host_memory_bytes = prometheus.NewDesc("host_memory_bytes", "memory information field from /proc/meminfo in bytes", []string{"field"}, nil)
+
+	host_network_receive_bytes_total    = prometheus.NewDesc("host_network_receive_bytes_total", "bytes received by a network device", []string{"device"}, nil)
+	host_network_receive_packets_total  = prometheus.NewDesc("host_network_receive_packets_total", "packets received by a network device", []string{"device"}, nil)
+	host_network_receive_errs_total     = prometheus.NewDesc("host_network_receive_errs_total", "receive errors of a network device", []string{"device"}, nil)
+	host_network_receive_drop_total     = prometheus.NewDesc("host_network_receive_drop_total", "packets dropped while receiving on a network device", []string{"device"}, nil)
+	host_network_transmit_bytes_total   = prometheus.NewDesc("host_network_transmit_bytes_total", "bytes transmitted by a network device", []string{"device"}, nil)
+	host_network_transmit_packets_total = prometheus.NewDesc("host_network_transmit_packets_total", "packets transmitted by a network device", []string{"device"}, nil)
+	host_network_transmit_errs_total    = prometheus.NewDesc("host_network_transmit_errs_total", "transmit errors of a network device", []string{"device"}, nil)
+	host_network_transmit_drop_total    = prometheus.NewDesc("host_network_transmit_drop_total", "packets dropped while transmitting on a network device", []string{"device"}, nil)
+
+	mountstats_label                              = []string{"export", "mountpoint"}
+	host_mountstats_nfs_age_seconds_total         = prometheus.NewDesc("host_mountstats_nfs_age_seconds_total", "seconds the NFS mount has been mounted", mountstats_label, nil)
+	host_mountstats_nfs_read_bytes_total          = prometheus.NewDesc("host_mountstats_nfs_read_bytes_total", "bytes read from the NFS mount", mountstats_label, nil)
+	host_mountstats_nfs_write_bytes_total         = prometheus.NewDesc("host_mountstats_nfs_write_bytes_total", "bytes written to the NFS mount", mountstats_label, nil)
+	host_mountstats_nfs_transport_sends_total     = prometheus.NewDesc("host_mountstats_nfs_transport_sends_total", "RPC requests sent to the NFS server", mountstats_label, nil)
+	host_mountstats_nfs_transport_receives_total  = prometheus.NewDesc("host_mountstats_nfs_transport_receives_total", "RPC replies received from the NFS server", mountstats_label, nil)
+	host_mountstats_nfs_operations_requests_total = prometheus.NewDesc("host_mountstats_nfs_operations_requests_total",
+		"requests of an NFS operation", append(mountstats_label, "operation"), nil)
+	host_mountstats_nfs_operations_major_timeouts_total = prometheus.NewDesc("host_mountstats_nfs_operations_major_timeouts_total",
+		"major timeouts of an NFS operation", append(mountstats_label, "operation"), nil)
+	host_mountstats_nfs_operations_response_time_seconds_total = prometheus.NewDesc("host_mountstats_nfs_operations_response_time_seconds_total",
+		"seconds spent waiting for replies to an NFS operation", append(mountstats_label, "operation"), nil)
+
+	host_md_is_active     = prometheus.NewDesc("host_md_is_active", "whether the md device is active", []string{"device"}, nil)
+	host_md_disks_active  = prometheus.NewDesc("host_md_disks_active", "active disks of the md device", []string{"device"}, nil)
+	host_md_disks         = prometheus.NewDesc("host_md_disks", "disks the md device consists of", []string{"device"}, nil)
+	host_md_degraded      = prometheus.NewDesc("host_md_degraded", "missing disks of the md device", []string{"device"}, nil)
+	host_md_blocks        = prometheus.NewDesc("host_md_blocks", "blocks of the md device", []string{"device"}, nil)
+	host_md_blocks_synced = prometheus.NewDesc("host_md_blocks_synced", "blocks of the md device in sync", []string{"device"}, nil)
+
+	host_xfs_read_calls_total                = prometheus.NewDesc("host_xfs_read_calls_total", "XFS read system calls", nil, nil)
+	host_xfs_write_calls_total               = prometheus.NewDesc("host_xfs_write_calls_total", "XFS write system calls", nil, nil)
+	host_xfs_extent_allocation_extents_total = prometheus.NewDesc("host_xfs_extent_allocation_extents_total", "XFS extents allocated and freed", []string{"op"}, nil)
+	host_xfs_block_mapping_total             = prometheus.NewDesc("host_xfs_block_mapping_total", "XFS block map operations", []string{"op"}, nil)
+	host_xfs_directory_operation_total       = prometheus.NewDesc("host_xfs_directory_operation_total", "XFS directory operations", []string{"op"}, nil)
+	host_xfs_inode_operation_total           = prometheus.NewDesc("host_xfs_inode_operation_total", "XFS inode cache operations", []string{"op"}, nil)
+	host_xfs_log_operation_writes_total      = prometheus.NewDesc("host_xfs_log_operation_writes_total", "XFS log buffer writes", nil, nil)
+	host_xfs_transaction_total               = prometheus.NewDesc("host_xfs_transaction_total", "XFS transactions", []string{"type"}, nil)
+
+	host_nfs_rpcs_total                         = prometheus.NewDesc("host_nfs_rpcs_total", "RPCs sent by the NFS client", nil, nil)
+	host_nfs_rpc_retransmissions_total          = prometheus.NewDesc("host_nfs_rpc_retransmissions_total", "RPCs retransmitted by the NFS client", nil, nil)
+	host_nfs_rpc_authentication_refreshes_total = prometheus.NewDesc("host_nfs_rpc_authentication_refreshes_total",
+		"RPC authentication refreshes of the NFS client", nil, nil)
+	host_nfs_packets_total     = prometheus.NewDesc("host_nfs_packets_total", "packets sent by the NFS client", []string{"protocol"}, nil)
+	host_nfs_connections_total = prometheus.NewDesc("host_nfs_connections_total", "TCP connections made by the NFS client", nil, nil)
+
+	host_buddyinfo_blocks = prometheus.NewDesc("host_buddyinfo_blocks", "free memory fragments of 2^size pages",
+		[]string{"node", "zone", "size"}, nil)
+)
+
+func (c HostCollector) Collect(ch chan<- prometheus.Metric) {
+	fs, err := procfs.NewFS(c.ProcPath)
+	if err != nil {
+		log.Printf("open procfs error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(host_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	for _, sc := range hostSubCollectors {
+		if sc.optional != "" {
+			if _, err := os.Stat(fs.Path(sc.optional)); os.IsNotExist(err) {
+				continue
+			}
+		}
+		success := 1.0
+		if err := sc.collect(ch, fs); err != nil {
+			log.Printf("collect host %s error: %s", sc.name, err.Error())
+			success = 0
+		}
+		ch <- prometheus.MustNewConstMetric(host_scrape_collector_success, prometheus.GaugeValue, success, sc.name)
+	}
+	ch <- prometheus.MustNewConstMetric(host_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+func collectHostCPU(ch chan<- prometheus.Metric, fs procfs.FS) error {
+	stat, err := fs.NewStat()
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(host_boot_time_seconds, prometheus.GaugeValue, float64(stat.BootTime))
+	for i, cpu := range stat.CPU {
+		name := strconv.Itoa(i)
+		modes := []struct {
+			mode  string
+			value float64
+		}{
+			{"user", cpu.User}, {"nice", cpu.Nice}, {"system", cpu.System}, {"idle", cpu.Idle},
+			{"iowait", cpu.Iowait}, {"irq", cpu.IRQ}, {"softirq", cpu.SoftIRQ}, {"steal", cpu.Steal},
+		}
+		for _, m := range modes {
+			ch <- prometheus.MustNewConstMetric(host_cpu_seconds_total, prometheus.CounterValue, m.value, name, m.mode)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(host_context_switches_total, prometheus.CounterValue, float64(stat.ContextSwitches))
+	ch <- prometheus.MustNewConstMetric(host_forks_total, prometheus.CounterValue, float64(stat.ProcessCreated))
+	ch <- prometheus.MustNewConstMetric(host_intr_total, prometheus.CounterValue, float64(stat.IRQTotal))
+	ch <- prometheus.MustNewConstMetric(host_procs_running, prometheus.GaugeValue, float64(stat.ProcessesRunning))
+	ch <- prometheus.MustNewConstMetric(host_procs_blocked, prometheus.GaugeValue, float64(stat.ProcessesBlocked))
+	return nil
+}
+
+// collectHostMemory reads /proc/meminfo, which the vendored procfs does not
+// parse. Only the fields given in kB are exported.
+func collectHostMemory(ch chan<- prometheus.Metric, fs procfs.FS) error {
+	f, err := os.Open(fs.Path("meminfo"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 3 || parts[2] != "kB" {
+			continue
+		}
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid meminfo line %q: %s", scanner.Text(), err.Error())
+		}
+		field := strings.TrimSuffix(parts[0], ":")
+		field = strings.NewReplacer("(", "_", ")", "").Replace(field)
+		ch <- prometheus.MustNewConstMetric(host_memory_bytes, prometheus.GaugeValue, value*1024, field)
+	}
+	return scanner.Err()
+}
+
+// collectHostNetDev reads the interfaces of the host network namespace
+// through its init process, net/dev of procfs itself is the exporter's.
+func collectHostNetDev(ch chan<- prometheus.Metric, fs procfs.FS) error {
+	hostFS, err := procfs.NewFS(fs.Path("1"))
+	if err != nil {
+		return err
+	}
+	netdev, err := hostFS.NewNetDev()
+	if err != nil {
+		return err
+	}
+	for name, line := range netdev {
+		ch <- prometheus.MustNewConstMetric(host_network_receive_bytes_total, prometheus.CounterValue, float64(line.RxBytes), name)
+		ch <- prometheus.MustNewConstMetric(host_network_receive_packets_total, prometheus.CounterValue, float64(line.RxPackets), name)
+		ch <- prometheus.MustNewConstMetric(host_network_receive_errs_total, prometheus.CounterValue, float64(line.RxErrors), name)
+		ch <- prometheus.MustNewConstMetric(host_network_receive_drop_total, prometheus.CounterValue, float64(line.RxDropped), name)
+		ch <- prometheus.MustNewConstMetric(host_network_transmit_bytes_total, prometheus.CounterValue, float64(line.TxBytes), name)
+		ch <- prometheus.MustNewConstMetric(host_network_transmit_packets_total, prometheus.CounterValue, float64(line.TxPackets), name)
+		ch <- prometheus.MustNewConstMetric(host_network_transmit_errs_total, prometheus.CounterValue, float64(line.TxErrors), name)
+		ch <- prometheus.MustNewConstMetric(host_network_transmit_drop_total, prometheus.CounterValue, float64(line.TxDropped), name)
+	}
+	return nil
+}
+
+// collectHostMountStats reads the NFS mounts of the host mount namespace
+// through its init process.
+func collectHostMountStats(ch chan<- prometheus.Metric, fs procfs.FS) error {
+	proc, err := fs.NewProc(1)
+	if err != nil {
+		return err
+	}
+	mounts, err := proc.MountStats()
+	if err != nil {
+		return err
+	}
+	// The same export can be mounted on the same path more than once, e.g.
+	// shadowed by a later mount. Only the first one is exported.
+	seen := map[string]bool{}
+	for _, m := range mounts {
+		stats, ok := m.Stats.(*procfs.MountStatsNFS)
+		if !ok {
+			continue
+		}
+		key := m.Device + " " + m.Mount
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		labelValues := []string{m.Device, m.Mount}
+		ch <- prometheus.MustNewConstMetric(host_mountstats_nfs_age_seconds_total, prometheus.CounterValue, stats.Age.Seconds(), labelValues...)
+		ch <- prometheus.MustNewConstMetric(host_mountstats_nfs_read_bytes_total, prometheus.CounterValue, float64(stats.Bytes.Read), labelValues...)
+		ch <- prometheus.MustNewConstMetric(host_mountstats_nfs_write_bytes_total, prometheus.CounterValue, float64(stats.Bytes.Write), labelValues...)
+		ch <- prometheus.MustNewConstMetric(host_mountstats_nfs_transport_sends_total, prometheus.CounterValue, float64(stats.Transport.Sends), labelValues...)
+		ch <- prometheus.MustNewConstMetric(host_mountstats_nfs_transport_receives_total, prometheus.CounterValue, float64(stats.Transport.Receives), labelValues...)
+		for _, op := range stats.Operations {
+			opLabelValues := append(labelValues, op.Operation)
+			ch <- prometheus.MustNewConstMetric(host_mountstats_nfs_operations_requests_total, prometheus.CounterValue, float64(op.Requests), opLabelValues...)
+			ch <- prometheus.MustNewConstMetric(host_mountstats_nfs_operations_major_timeouts_total, prometheus.CounterValue, float64(op.MajorTimeouts), opLabelValues...)
+			ch <- prometheus.MustNewConstMetric(host_mountstats_nfs_operations_response_time_seconds_total, prometheus.CounterValue,
+				op.CumulativeTotalResponseTime.Seconds(), opLabelValues...)
+		}
+	}
+	return nil
+}
+
+func collectHostMDStat(ch chan<- prometheus.Metric, fs procfs.FS) error {
+	mdstats, err := fs.ParseMDStat()
+	if err != nil {
+		return err
+	}
+	for _, md := range mdstats {
+		active := 0.0
+		if md.ActivityState == "active" {
+			active = 1
+		}
+		ch <- prometheus.MustNewConstMetric(host_md_is_active, prometheus.GaugeValue, active, md.Name)
+		ch <- prometheus.MustNewConstMetric(host_md_disks_active, prometheus.GaugeValue, float64(md.DisksActive), md.Name)
+		ch <- prometheus.MustNewConstMetric(host_md_disks, prometheus.GaugeValue, float64(md.DisksTotal), md.Name)
+		ch <- prometheus.MustNewConstMetric(host_md_degraded, prometheus.GaugeValue, float64(md.DisksTotal-md.DisksActive), md.Name)
+		ch <- prometheus.MustNewConstMetric(host_md_blocks, prometheus.GaugeValue, float64(md.BlocksTotal), md.Name)
+		ch <- prometheus.MustNewConstMetric(host_md_blocks_synced, prometheus.GaugeValue, float64(md.BlocksSynced), md.Name)
+	}
+	return nil
+}
+
+func collectHostXFS(ch chan<- prometheus.Metric, fs procfs.FS) error {
+	stats, err := fs.XFSStats()
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(host_xfs_read_calls_total, prometheus.CounterValue, float64(stats.ReadWrite.Read))
+	ch <- prometheus.MustNewConstMetric(host_xfs_write_calls_total, prometheus.CounterValue, float64(stats.ReadWrite.Write))
+	ch <- prometheus.MustNewConstMetric(host_xfs_extent_allocation_extents_total, prometheus.CounterValue, float64(stats.ExtentAllocation.ExtentsAllocated), "allocated")
+	ch <- prometheus.MustNewConstMetric(host_xfs_extent_allocation_extents_total, prometheus.CounterValue, float64(stats.ExtentAllocation.ExtentsFreed), "freed")
+	ch <- prometheus.MustNewConstMetric(host_xfs_block_mapping_total, prometheus.CounterValue, float64(stats.BlockMapping.Reads), "read")
+	ch <- prometheus.MustNewConstMetric(host_xfs_block_mapping_total, prometheus.CounterValue, float64(stats.BlockMapping.Writes), "write")
+	ch <- prometheus.MustNewConstMetric(host_xfs_block_mapping_total, prometheus.CounterValue, float64(stats.BlockMapping.Unmaps), "unmap")
+	ch <- prometheus.MustNewConstMetric(host_xfs_directory_operation_total, prometheus.CounterValue, float64(stats.DirectoryOperation.Lookups), "lookup")
+	ch <- prometheus.MustNewConstMetric(host_xfs_directory_operation_total, prometheus.CounterValue, float64(stats.DirectoryOperation.Creates), "create")
+	ch <- prometheus.MustNewConstMetric(host_xfs_directory_operation_total, prometheus.CounterValue, float64(stats.DirectoryOperation.Removes), "remove")
+	ch <- prometheus.MustNewConstMetric(host_xfs_directory_operation_total, prometheus.CounterValue, float64(stats.DirectoryOperation.Getdents), "getdents")
+	ch <- prometheus.MustNewConstMetric(host_xfs_inode_operation_total, prometheus.CounterValue, float64(stats.InodeOperation.Attempts), "attempts")
+	ch <- prometheus.MustNewConstMetric(host_xfs_inode_operation_total, prometheus.CounterValue, float64(stats.InodeOperation.Found), "found")
+	ch <- prometheus.MustNewConstMetric(host_xfs_inode_operation_total, prometheus.CounterValue, float64(stats.InodeOperation.Missed), "missed")
+	ch <- prometheus.MustNewConstMetric(host_xfs_inode_operation_total, prometheus.CounterValue, float64(stats.InodeOperation.Reclaims), "reclaims")
+	ch <- prometheus.MustNewConstMetric(host_xfs_log_operation_writes_total, prometheus.CounterValue, float64(stats.LogOperation.Writes))
+	ch <- prometheus.MustNewConstMetric(host_xfs_transaction_total, prometheus.CounterValue, float64(stats.Transaction.Sync), "sync")
+	ch <- prometheus.MustNewConstMetric(host_xfs_transaction_total, prometheus.CounterValue, float64(stats.Transaction.Async), "async")
+	ch <- prometheus.MustNewConstMetric(host_xfs_transaction_total, prometheus.CounterValue, float64(stats.Transaction.Empty), "empty")
+	return nil
+}
+
+func collectHostNFS(ch chan<- prometheus.Metric, fs procfs.FS) error {
+	stats, err := fs.NFSClientRPCStats()
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(host_nfs_rpcs_total, prometheus.CounterValue, float64(stats.ClientRPC.RPCCount))
+	ch <- prometheus.MustNewConstMetric(host_nfs_rpc_retransmissions_total, prometheus.CounterValue, float64(stats.ClientRPC.Retransmissions))
+	ch <- prometheus.MustNewConstMetric(host_nfs_rpc_authentication_refreshes_total, prometheus.CounterValue, float64(stats.ClientRPC.AuthRefreshes))
+	ch <- prometheus.MustNewConstMetric(host_nfs_packets_total, prometheus.CounterValue, float64(stats.Network.UDPCount), "udp")
+	ch <- prometheus.MustNewConstMetric(host_nfs_packets_total, prometheus.CounterValue, float64(stats.Network.TCPCount), "tcp")
+	ch <- prometheus.MustNewConstMetric(host_nfs_connections_total, prometheus.CounterValue, float64(stats.Network.TCPConnect))
+	return nil
+}
+
+func collectHostBuddyInfo(ch chan<- prometheus.Metric, fs procfs.FS) error {
+	buddyinfo, err := fs.NewBuddyInfo()
+	if err != nil {
+		return err
+	}
+	for _, entry := range buddyinfo {
+		for size, value := range entry.Sizes {
+			ch <- prometheus.MustNewConstMetric(host_buddyinfo_blocks, prometheus.GaugeValue, value, entry.Node, entry.Zone, strconv.Itoa(size))
+		}
+	}
+	return nil
+}
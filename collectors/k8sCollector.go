@@ -3,10 +3,11 @@ package collectors
 import (
 	"github.com/prometheus/client_golang/prometheus"
 	"container-exporter/config"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/kubernetes"
+	"container-exporter/collectors/kube"
 	"log"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
 )
 
 type K8sCollector struct {
@@ -18,51 +19,256 @@ var (
 	k8s_cluster_monitorstatus  = prometheus.NewDesc("k8s_cluster_monitorstatus", "k8s cluster node monitor status", nil, nil)
 	k8s_cluster_containers_total = prometheus.NewDesc("k8s_cluster_containers_total", "k8s cluster containers in total", nil, nil)
 	k8s_cluster_memory_total  = prometheus.NewDesc("k8s_cluster_memory_total", "k8s cluster memory in total", nil, nil)
+	k8s_cluster_allocatable = prometheus.NewDesc("k8s_cluster_allocatable", "k8s cluster allocatable resources, cpu in cores and others in bytes or pods", []string{"resource"}, nil)
+	k8s_cluster_requests = prometheus.NewDesc("k8s_cluster_requests", "k8s cluster resources requested by scheduled non-terminal pods", []string{"resource"}, nil)
+	k8s_cluster_limits = prometheus.NewDesc("k8s_cluster_limits", "k8s cluster resource limits of scheduled non-terminal pods", []string{"resource"}, nil)
+	k8s_cluster_requests_ratio = prometheus.NewDesc("k8s_cluster_requests_ratio", "k8s cluster requests divided by allocatable", []string{"resource"}, nil)
+	k8s_cluster_limits_ratio = prometheus.NewDesc("k8s_cluster_limits_ratio", "k8s cluster limits divided by allocatable", []string{"resource"}, nil)
+	k8s_cluster_pods = prometheus.NewDesc("k8s_cluster_pods", "k8s cluster pods by phase", []string{"phase"}, nil)
+	k8s_cluster_containers = prometheus.NewDesc("k8s_cluster_containers", "k8s cluster containers by state", []string{"state"}, nil)
+	k8s_cluster_container_state_reason = prometheus.NewDesc("k8s_cluster_container_state_reason", "k8s cluster waiting and terminated containers by reason", []string{"state","reason"}, nil)
+	k8s_namespace_pods = prometheus.NewDesc("k8s_namespace_pods", "pods of a namespace by phase", []string{"namespace","phase"}, nil)
+	k8s_namespace_containers = prometheus.NewDesc("k8s_namespace_containers", "containers of a namespace by state", []string{"namespace","state"}, nil)
+	k8s_namespace_container_state_reason = prometheus.NewDesc("k8s_namespace_container_state_reason", "waiting and terminated containers of a namespace by reason", []string{"namespace","state","reason"}, nil)
+	role_label = []string{"role","resource"}
+	k8s_cluster_role_allocatable = prometheus.NewDesc("k8s_cluster_role_allocatable", "allocatable resources of the nodes of a role", role_label, nil)
+	k8s_cluster_role_requests = prometheus.NewDesc("k8s_cluster_role_requests", "resources requested by scheduled non-terminal pods on the nodes of a role", role_label, nil)
+	k8s_cluster_role_limits = prometheus.NewDesc("k8s_cluster_role_limits", "resource limits of scheduled non-terminal pods on the nodes of a role", role_label, nil)
+	k8s_cluster_role_requests_ratio = prometheus.NewDesc("k8s_cluster_role_requests_ratio", "requests divided by allocatable on the nodes of a role", role_label, nil)
+	k8s_cluster_role_limits_ratio = prometheus.NewDesc("k8s_cluster_role_limits_ratio", "limits divided by allocatable on the nodes of a role", role_label, nil)
 )
+
+var containerStates = []string{"running","waiting","terminated"}
+
+type stateReason struct {
+	state string
+	reason string
+}
+
+// podStates counts pods and containers of a namespace, or of the cluster.
+type podStates struct {
+	phases map[apiv1.PodPhase]int
+	states map[string]int
+	reasons map[stateReason]int
+}
+
+func newPodStates() *podStates {
+	return &podStates{map[apiv1.PodPhase]int{},map[string]int{},map[stateReason]int{}}
+}
+
+func (p *podStates) add(pod *apiv1.Pod) {
+	phase := pod.Status.Phase
+	if phase == "" {
+		phase = apiv1.PodUnknown
+	}
+	p.phases[phase]++
+	for _,status := range pod.Status.ContainerStatuses{
+		switch {
+		case status.State.Running != nil:
+			p.states["running"]++
+		case status.State.Waiting != nil:
+			p.states["waiting"]++
+			if status.State.Waiting.Reason != "" {
+				p.reasons[stateReason{"waiting",status.State.Waiting.Reason}]++
+			}
+		case status.State.Terminated != nil:
+			p.states["terminated"]++
+			if status.State.Terminated.Reason != "" {
+				p.reasons[stateReason{"terminated",status.State.Terminated.Reason}]++
+			}
+		}
+	}
+}
+
+// collectPodStates exports pod phases and container states cluster-wide, with
+// every phase and state present, and per namespace, where only the ones seen
+// are.
+func collectPodStates(ch chan<- prometheus.Metric,pods []*apiv1.Pod) {
+	cluster := newPodStates()
+	namespaces := map[string]*podStates{}
+	for _,v := range pods{
+		cluster.add(v)
+		if namespaces[v.Namespace] == nil {
+			namespaces[v.Namespace] = newPodStates()
+		}
+		namespaces[v.Namespace].add(v)
+	}
+	for _,phase := range podPhases{
+		ch <- prometheus.MustNewConstMetric(k8s_cluster_pods,prometheus.GaugeValue,float64(cluster.phases[phase]),string(phase))
+	}
+	for _,state := range containerStates{
+		ch <- prometheus.MustNewConstMetric(k8s_cluster_containers,prometheus.GaugeValue,float64(cluster.states[state]),state)
+	}
+	for r,count := range cluster.reasons{
+		ch <- prometheus.MustNewConstMetric(k8s_cluster_container_state_reason,prometheus.GaugeValue,float64(count),r.state,r.reason)
+	}
+	for namespace,states := range namespaces{
+		for phase,count := range states.phases{
+			ch <- prometheus.MustNewConstMetric(k8s_namespace_pods,prometheus.GaugeValue,float64(count),namespace,string(phase))
+		}
+		for state,count := range states.states{
+			ch <- prometheus.MustNewConstMetric(k8s_namespace_containers,prometheus.GaugeValue,float64(count),namespace,state)
+		}
+		for r,count := range states.reasons{
+			ch <- prometheus.MustNewConstMetric(k8s_namespace_container_state_reason,prometheus.GaugeValue,float64(count),namespace,r.state,r.reason)
+		}
+	}
+}
+
+// allocatableResources are the node resources the capacity metrics are
+// reported for. Requests of "pods" count the scheduled pods.
+var allocatableResources = []apiv1.ResourceName{apiv1.ResourceCPU, apiv1.ResourceMemory, apiv1.ResourcePods, "ephemeral-storage"}
+
+const nodeRoleNone = "none"
+
+type resourceTotals map[apiv1.ResourceName]float64
+
+func (t resourceTotals) add(o resourceTotals) {
+	for name, v := range o {
+		t[name] += v
+	}
+}
+
+// resourceUsage is what the pods of a group of nodes ask for against what
+// the nodes offer.
+type resourceUsage struct {
+	allocatable resourceTotals
+	requests    resourceTotals
+	limits      resourceTotals
+}
+
+func newResourceUsage() *resourceUsage {
+	return &resourceUsage{resourceTotals{}, resourceTotals{}, resourceTotals{}}
+}
+
+// nodeRole reads the role of a node from the node-role.kubernetes.io/<role>
+// or kubernetes.io/role labels.
+func nodeRole(node *apiv1.Node) string {
+	var roles []string
+	for k := range node.Labels {
+		if strings.HasPrefix(k, "node-role.kubernetes.io/") {
+			roles = append(roles, strings.TrimPrefix(k, "node-role.kubernetes.io/"))
+		}
+	}
+	if len(roles) > 0 {
+		sort.Strings(roles)
+		return strings.Join(roles, ",")
+	}
+	if role := node.Labels["kubernetes.io/role"]; role != "" {
+		return role
+	}
+	return nodeRoleNone
+}
+
+// podResources sums requests and limits of the containers of a pod, raised to
+// the largest init container the way the scheduler accounts for them.
+func podResources(pod *apiv1.Pod) (resourceTotals, resourceTotals) {
+	requests, limits := resourceTotals{}, resourceTotals{}
+	for _, c := range pod.Spec.Containers {
+		for _, name := range allocatableResources {
+			if q, ok := c.Resources.Requests[name]; ok {
+				requests[name] += quantityValue(name, q)
+			}
+			if q, ok := c.Resources.Limits[name]; ok {
+				limits[name] += quantityValue(name, q)
+			}
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		for _, name := range allocatableResources {
+			if q, ok := c.Resources.Requests[name]; ok && quantityValue(name, q) > requests[name] {
+				requests[name] = quantityValue(name, q)
+			}
+			if q, ok := c.Resources.Limits[name]; ok && quantityValue(name, q) > limits[name] {
+				limits[name] = quantityValue(name, q)
+			}
+		}
+	}
+	requests[apiv1.ResourcePods] = 1
+	return requests, limits
+}
+
+func collectResourceUsage(ch chan<- prometheus.Metric, usage *resourceUsage, allocatable, requests, limits, requestsRatio, limitsRatio *prometheus.Desc, labelValues ...string) {
+	for _, name := range allocatableResources {
+		values := append(labelValues, string(name))
+		ch <- prometheus.MustNewConstMetric(allocatable, prometheus.GaugeValue, usage.allocatable[name], values...)
+		ch <- prometheus.MustNewConstMetric(requests, prometheus.GaugeValue, usage.requests[name], values...)
+		if name != apiv1.ResourcePods {
+			ch <- prometheus.MustNewConstMetric(limits, prometheus.GaugeValue, usage.limits[name], values...)
+		}
+		if usage.allocatable[name] == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(requestsRatio, prometheus.GaugeValue, usage.requests[name]/usage.allocatable[name], values...)
+		if name != apiv1.ResourcePods {
+			ch <- prometheus.MustNewConstMetric(limitsRatio, prometheus.GaugeValue, usage.limits[name]/usage.allocatable[name], values...)
+		}
+	}
+}
 func (c K8sCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
 }
 
 func (c K8sCollector) Collect(ch chan<- prometheus.Metric) {
 	monitor_info := config.GetMonitorInfo(c.Target)
-	master_IP := monitor_info.Params_maps["master_ip"]
-	aport := monitor_info.Params_maps["api_port"]
-	endpoint := master_IP + ":" + aport
-	config := &rest.Config{
-		Host: "http://" + endpoint,
-	}
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Printf("get clientset error: %s", err.Error())
-		ch <- prometheus.MustNewConstMetric(k8s_cluster_monitorstatus, prometheus.GaugeValue, float64(0))
-		return
-	}
-	nodelist, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
-	if err != nil {
-		log.Printf("get node list error: %s", err.Error())
-		ch <- prometheus.MustNewConstMetric(k8s_cluster_monitorstatus, prometheus.GaugeValue, float64(0))
-		return
-	}
-	pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
 	if err != nil {
-		log.Printf("get pods list error: %s", err.Error())
+		log.Printf("get cluster cache error: %s", err.Error())
 		ch <- prometheus.MustNewConstMetric(k8s_cluster_monitorstatus, prometheus.GaugeValue, float64(0))
 		return
 	}
+	nodelist := clustercache.Nodes()
+	pods := clustercache.Pods()
 	var containercount float64 =0
-	for _,v := range pods.Items{
+	for _,v := range pods{
+		// Containers of completed pods are not running anymore.
+		if v.Status.Phase == apiv1.PodSucceeded || v.Status.Phase == apiv1.PodFailed {
+			continue
+		}
 		cons := v.Spec.Containers
 		containercount = containercount + float64(len(cons))
 	}
+	collectPodStates(ch,pods)
 	var totalcore float64 = 0
 	var totalmemory float64 = 0
-	for _,v := range nodelist.Items{
+	cluster := newResourceUsage()
+	roles := map[string]*resourceUsage{}
+	nodeRoles := map[string]string{}
+	for _,v := range nodelist{
 		core := v.Status.Capacity.Cpu().Value()
 		memory := v.Status.Capacity.Memory().Value()
 		totalcore = totalcore + float64(core)
 		totalmemory = totalmemory + float64(memory)
+		role := nodeRole(v)
+		nodeRoles[v.Name] = role
+		if roles[role] == nil {
+			roles[role] = newResourceUsage()
+		}
+		for _, name := range allocatableResources {
+			if q, ok := v.Status.Allocatable[name]; ok {
+				roles[role].allocatable[name] += quantityValue(name, q)
+			}
 		}
-	ch <- prometheus.MustNewConstMetric(k8s_cluster_nodes_total,prometheus.GaugeValue,float64(len(nodelist.Items)))
+	}
+	for _, v := range pods {
+		role, ok := nodeRoles[v.Spec.NodeName]
+		if !ok || v.Status.Phase == apiv1.PodSucceeded || v.Status.Phase == apiv1.PodFailed {
+			continue
+		}
+		requests, limits := podResources(v)
+		roles[role].requests.add(requests)
+		roles[role].limits.add(limits)
+	}
+	for role, usage := range roles {
+		cluster.allocatable.add(usage.allocatable)
+		cluster.requests.add(usage.requests)
+		cluster.limits.add(usage.limits)
+		collectResourceUsage(ch, usage, k8s_cluster_role_allocatable, k8s_cluster_role_requests, k8s_cluster_role_limits,
+			k8s_cluster_role_requests_ratio, k8s_cluster_role_limits_ratio, role)
+	}
+	collectResourceUsage(ch, cluster, k8s_cluster_allocatable, k8s_cluster_requests, k8s_cluster_limits,
+		k8s_cluster_requests_ratio, k8s_cluster_limits_ratio)
+	ch <- prometheus.MustNewConstMetric(k8s_cluster_nodes_total,prometheus.GaugeValue,float64(len(nodelist)))
 	ch <- prometheus.MustNewConstMetric(k8s_cluster_containers_total,prometheus.GaugeValue,containercount)
 	ch <- prometheus.MustNewConstMetric(k8s_cluster_cpucores_total,prometheus.GaugeValue,totalcore)
 	ch <- prometheus.MustNewConstMetric(k8s_cluster_memory_total,prometheus.GaugeValue,totalmemory)
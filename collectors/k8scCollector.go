@@ -4,12 +4,11 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/google/cadvisor/info/v1"
 	"container-exporter/config"
-	"github.com/google/cadvisor/client"
 	"log"
-	"regexp"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/kubernetes"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"container-exporter/collectors/kube"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"strings"
 	"time"
 	"fmt"
 )
@@ -37,6 +36,10 @@ var containerMetrics = []containerMetric{
 		valueType:prometheus.CounterValue,
 		extraLabels:[]string{"cpu"},
 		getValues: func(s *v1.ContainerStats) metricValues {
+			if len(s.Cpu.Usage.PerCpu) == 0 {
+				// The kubelet summary only reports the total.
+				return metricValues{{value:float64(s.Cpu.Usage.Total)/float64(time.Second),labels:[]string{"total"}}}
+			}
 			values := make(metricValues,0,len(s.Cpu.Usage.PerCpu))
 			for i,value := range s.Cpu.Usage.PerCpu{
 				values = append(values,metricValue{
@@ -46,6 +49,49 @@ var containerMetrics = []containerMetric{
 			}
 			return values
 		},
+	},{
+		name:"k8s_container_cpu_user_seconds_total",
+		help:"Cumulative user cpu time consumed in seconds",
+		valueType:prometheus.CounterValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Cpu.Usage.User)/float64(time.Second)}}
+		},
+	},{
+		name:"k8s_container_cpu_system_seconds_total",
+		help:"Cumulative system cpu time consumed in seconds",
+		valueType:prometheus.CounterValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Cpu.Usage.System)/float64(time.Second)}}
+		},
+	},{
+		name:"k8s_container_cpu_cfs_periods_total",
+		help:"Number of elapsed enforcement period intervals",
+		valueType:prometheus.CounterValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Cpu.CFS.Periods)}}
+		},
+	},{
+		name:"k8s_container_cpu_cfs_throttled_periods_total",
+		help:"Number of throttled period intervals",
+		valueType:prometheus.CounterValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Cpu.CFS.ThrottledPeriods)}}
+		},
+	},{
+		name:"k8s_container_cpu_cfs_throttled_seconds_total",
+		help:"Total time duration the container has been throttled in seconds",
+		valueType:prometheus.CounterValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Cpu.CFS.ThrottledTime)/float64(time.Second)}}
+		},
+	},{
+		name:"k8s_container_cpu_load_average_10s",
+		help:"Value of container cpu load average over the last 10 seconds",
+		valueType:prometheus.GaugeValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			// cadvisor reports the load average multiplied by 1000.
+			return metricValues{{value:float64(s.Cpu.LoadAverage)/1000}}
+		},
 	},{
 		name:"k8s_container_memory_usage_bytes",
 		help:"Current memory usage in bytes",
@@ -54,6 +100,68 @@ var containerMetrics = []containerMetric{
 			return metricValues{{value:float64(s.Memory.Usage)}}
 		},
 	},
+	{
+		name:"k8s_container_memory_working_set_bytes",
+		help:"Current working set in bytes",
+		valueType:prometheus.GaugeValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Memory.WorkingSet)}}
+		},
+	},
+	{
+		name:"k8s_container_memory_rss",
+		help:"Size of RSS in bytes",
+		valueType:prometheus.GaugeValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Memory.RSS)}}
+		},
+	},
+	{
+		name:"k8s_container_memory_cache",
+		help:"Number of bytes of page cache memory",
+		valueType:prometheus.GaugeValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Memory.Cache)}}
+		},
+	},
+	{
+		name:"k8s_container_memory_swap",
+		help:"Container swap usage in bytes",
+		valueType:prometheus.GaugeValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Memory.Swap)}}
+		},
+	},
+	{
+		name:"k8s_container_memory_mapped_file",
+		help:"Size of memory mapped files in bytes",
+		valueType:prometheus.GaugeValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Memory.MappedFile)}}
+		},
+	},
+	{
+		name:"k8s_container_memory_failcnt",
+		help:"Number of memory usage hits limits",
+		valueType:prometheus.CounterValue,
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{{value:float64(s.Memory.Failcnt)}}
+		},
+	},
+	{
+		name:"k8s_container_memory_failures_total",
+		help:"Cumulative count of memory allocation failures",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"type","scope"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return metricValues{
+				{value:float64(s.Memory.ContainerData.Pgfault),labels:[]string{"pgfault","container"}},
+				{value:float64(s.Memory.ContainerData.Pgmajfault),labels:[]string{"pgmajfault","container"}},
+				{value:float64(s.Memory.HierarchicalData.Pgfault),labels:[]string{"pgfault","hierarchy"}},
+				{value:float64(s.Memory.HierarchicalData.Pgmajfault),labels:[]string{"pgmajfault","hierarchy"}},
+			}
+		},
+	},
 	{
 		name:"k8s_container_fs_limit_bytes",
 		help:"Number of bytes that can be consumed by the container on this filesystem",
@@ -76,6 +184,112 @@ var containerMetrics = []containerMetric{
 			})
 		},
 	},
+	{
+		name:"k8s_container_network_receive_bytes_total",
+		help:"Cumulative count of bytes received",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"interface"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return networkValues(s.Network.Interfaces, func(is *v1.InterfaceStats) float64 {
+				return float64(is.RxBytes)
+			})
+		},
+	},
+	{
+		name:"k8s_container_network_receive_packets_total",
+		help:"Cumulative count of packets received",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"interface"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return networkValues(s.Network.Interfaces, func(is *v1.InterfaceStats) float64 {
+				return float64(is.RxPackets)
+			})
+		},
+	},
+	{
+		name:"k8s_container_network_receive_errors_total",
+		help:"Cumulative count of errors received",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"interface"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return networkValues(s.Network.Interfaces, func(is *v1.InterfaceStats) float64 {
+				return float64(is.RxErrors)
+			})
+		},
+	},
+	{
+		name:"k8s_container_network_receive_packets_dropped_total",
+		help:"Cumulative count of packets dropped received",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"interface"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return networkValues(s.Network.Interfaces, func(is *v1.InterfaceStats) float64 {
+				return float64(is.RxDropped)
+			})
+		},
+	},
+	{
+		name:"k8s_container_network_transmit_bytes_total",
+		help:"Cumulative count of bytes transmitted",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"interface"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return networkValues(s.Network.Interfaces, func(is *v1.InterfaceStats) float64 {
+				return float64(is.TxBytes)
+			})
+		},
+	},
+	{
+		name:"k8s_container_network_transmit_packets_total",
+		help:"Cumulative count of packets transmitted",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"interface"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return networkValues(s.Network.Interfaces, func(is *v1.InterfaceStats) float64 {
+				return float64(is.TxPackets)
+			})
+		},
+	},
+	{
+		name:"k8s_container_network_transmit_errors_total",
+		help:"Cumulative count of errors transmitted",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"interface"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return networkValues(s.Network.Interfaces, func(is *v1.InterfaceStats) float64 {
+				return float64(is.TxErrors)
+			})
+		},
+	},
+	{
+		name:"k8s_container_network_transmit_packets_dropped_total",
+		help:"Cumulative count of packets dropped transmitted",
+		valueType:prometheus.CounterValue,
+		extraLabels:[]string{"interface"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return networkValues(s.Network.Interfaces, func(is *v1.InterfaceStats) float64 {
+				return float64(is.TxDropped)
+			})
+		},
+	},
+	{
+		name:"k8s_container_network_tcp_connections",
+		help:"Number of tcp connections of the container by state",
+		valueType:prometheus.GaugeValue,
+		extraLabels:[]string{"state"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return tcpValues(s.Network.Tcp)
+		},
+	},
+	{
+		name:"k8s_container_network_tcp6_connections",
+		help:"Number of tcp6 connections of the container by state",
+		valueType:prometheus.GaugeValue,
+		extraLabels:[]string{"state"},
+		getValues: func(s *v1.ContainerStats) metricValues {
+			return tcpValues(s.Network.Tcp6)
+		},
+	},
 }
 
 func fsValues(fsStats []v1.FsStats, valueFn func(fs *v1.FsStats) float64) metricValues {
@@ -88,6 +302,40 @@ func fsValues(fsStats []v1.FsStats, valueFn func(fs *v1.FsStats) float64) metric
 	}
 	return values
 }
+func networkValues(ifaceStats []v1.InterfaceStats, valueFn func(is *v1.InterfaceStats) float64) metricValues {
+	values := make(metricValues,0,len(ifaceStats))
+	for _,stat := range ifaceStats{
+		values = append(values,metricValue{
+			value: valueFn(&stat),
+			labels:[]string{stat.Name},
+		})
+	}
+	return values
+}
+
+// tcpValues returns nothing when cadvisor does not collect tcp stats, which
+// shows as every state being zero.
+func tcpValues(t v1.TcpStat) metricValues {
+	values := metricValues{
+		{value:float64(t.Established),labels:[]string{"established"}},
+		{value:float64(t.SynSent),labels:[]string{"synsent"}},
+		{value:float64(t.SynRecv),labels:[]string{"synrecv"}},
+		{value:float64(t.FinWait1),labels:[]string{"finwait1"}},
+		{value:float64(t.FinWait2),labels:[]string{"finwait2"}},
+		{value:float64(t.TimeWait),labels:[]string{"timewait"}},
+		{value:float64(t.Close),labels:[]string{"close"}},
+		{value:float64(t.CloseWait),labels:[]string{"closewait"}},
+		{value:float64(t.LastAck),labels:[]string{"lastack"}},
+		{value:float64(t.Listen),labels:[]string{"listen"}},
+		{value:float64(t.Closing),labels:[]string{"closing"}},
+	}
+	for _,v := range values{
+		if v.value != 0 {
+			return values
+		}
+	}
+	return nil
+}
 const maxMemorySize  = uint64(1 << 62)
 func specMemoryValue(v uint64) float64 {
 	if v > maxMemorySize {
@@ -116,44 +364,55 @@ func (cm *containerMetric) desc(baseLabels []string) *prometheus.Desc {
 func (c K8sContainerCollector)Collect(ch chan<-prometheus.Metric ) {
 	monitor_info := config.GetMonitorInfo(c.Target)
 	nodeIp:= monitor_info.Params_maps["node_ip"]
-	masterIp:= monitor_info.Params_maps["master_ip"]
 	contaninerID:=monitor_info.Params_maps["container_id"]
 	podname := monitor_info.Params_maps["pod_name"]
 	podnamespace := monitor_info.Params_maps["pod_namespace"]
-	aport := monitor_info.Params_maps["api_port"]
-	cport := monitor_info.Params_maps["cadvisor_port"]
-	cadvisorendpoint := nodeIp+":"+cport
-	client,err := client.NewClient("http://"+cadvisorendpoint)
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_container_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	pod, err := clustercache.Pod(podnamespace,podname)
+	if err != nil {
+		log.Printf("get pod error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_container_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	status := findContainerStatus(pod,contaninerID)
+	if status == nil {
+		log.Printf("container %s not found in pod %s/%s",contaninerID,podnamespace,podname)
+		ch <- prometheus.MustNewConstMetric(k8s_container_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	node, err := clustercache.Node(pod.Spec.NodeName)
+	if err != nil {
+		log.Printf("get node error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_container_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	source,err := newContainerSource(monitor_info.Params_maps,nodeIp,node)
 	if err!=nil {
 		log.Printf("get client error: %s",err.Error())
 		ch<-prometheus.MustNewConstMetric(k8s_container_monitorstatus,prometheus.GaugeValue,float64(0))
 		return
 	}
-	request := v1.ContainerInfoRequest{NumStats:1}
-	cinfo,err2 := client.DockerContainer(contaninerID,&request)
+	cinfo,err2 := source.containerInfo(pod,status)
 	if err2!=nil {
 		log.Printf("get containerinfo error: %s",err2.Error())
 		ch<-prometheus.MustNewConstMetric(k8s_container_monitorstatus,prometheus.GaugeValue,float64(0))
 		return
 	}
-	baseLabels := []string{"id"}
-	id:=cinfo.Name
-	name:=id
-	if len(cinfo.Aliases) >0{
-		name = cinfo.Aliases[0]
-		baseLabels = append(baseLabels,"name")
-	}
-	baseLabels = append(baseLabels,"nodeIP")
-	baseLabelValues := []string{id,name,nodeIp}[:len(baseLabels)]
-	newLabels := containerNameToLabels(name)
-	for k,v := range newLabels{
-		baseLabels =append(baseLabels,k)
-		baseLabelValues = append(baseLabelValues,v)
+	if len(cinfo.Stats) == 0 {
+		log.Printf("no stats for container %s",contaninerID)
+		ch<-prometheus.MustNewConstMetric(k8s_container_monitorstatus,prometheus.GaugeValue,float64(0))
+		return
 	}
-	minfo,err := client.MachineInfo()
+	minfo,err := source.machineInfo()
 	if err!=nil {
 		log.Printf("get machineinfo error: %s",err.Error())
 		ch<-prometheus.MustNewConstMetric(k8s_container_monitorstatus,prometheus.GaugeValue,float64(0))
+		return
 	}
 	core := minfo.NumCores
 	memory := minfo.MemoryCapacity
@@ -164,7 +423,23 @@ func (c K8sContainerCollector)Collect(ch chan<-prometheus.Metric ) {
 		"on the node",[]string{"nodeIP"},nil)
 	ch<-prometheus.MustNewConstMetric(machine_memory,prometheus.GaugeValue,float64(memory),[]string{nodeIp}...)
 
-	containername := newLabels["kubernetes_container_name"]
+	collectContainer(ch,cinfo,minfo,pod,status,nodeIp)
+	pod_warnings := prometheus.NewDesc("k8s_container_pod_warning_events", "recent warning events of the pod of the container by reason",
+		[]string{"kubernetes_pod_name","kubernetes_namespace","reason"}, nil)
+	collectRecentWarnings(ch,clustercache,pod_warnings,"Pod",pod.Namespace,pod.Name,[]string{pod.Name,pod.Namespace})
+	collectPodHPA(ch,clustercache,pod)
+	ch<-prometheus.MustNewConstMetric(k8s_container_monitorstatus,prometheus.GaugeValue,float64(1))
+}
+
+// collectContainer emits the spec, status and stats metrics of one container.
+// cinfo must hold at least one stats sample.
+func collectContainer(ch chan<-prometheus.Metric,cinfo *v1.ContainerInfo,minfo *v1.MachineInfo,pod *apiv1.Pod,status *apiv1.ContainerStatus,nodeIp string) {
+	baseLabels := []string{"id","name","nodeIP","runtime","kubernetes_container_name","kubernetes_pod_name","kubernetes_namespace"}
+	name := containerIDOf(status.ContainerID)
+	if len(cinfo.Aliases) >0{
+		name = cinfo.Aliases[0]
+	}
+	baseLabelValues := []string{cinfo.Name,name,nodeIp,containerRuntime(status.ContainerID),status.Name,pod.Name,pod.Namespace}
 	desc := prometheus.NewDesc("k8s_container_start_time_seconds", "start time of the container since unix epoch in seconds", baseLabels, nil)
 	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(cinfo.Spec.CreationTime.Unix()),baseLabelValues...)
 	if cinfo.Spec.HasCpu {
@@ -183,62 +458,213 @@ func (c K8sContainerCollector)Collect(ch chan<-prometheus.Metric ) {
 		desc = prometheus.NewDesc("k8s_container_spec_memory_swap_limit_bytes", "memory swap limit for the container", baseLabels, nil)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, specMemoryValue(cinfo.Spec.Memory.SwapLimit),baseLabelValues...)
 	}
-	stats := cinfo.Stats[0]
-	k8sendpoint := masterIp +":"+aport
-	config := &rest.Config{
-		Host:"http://" + k8sendpoint,
+	stats := cinfo.Stats[len(cinfo.Stats)-1]
+	if usage,ok := cpuLimitUsage(cinfo);ok {
+		desc = prometheus.NewDesc("k8s_container_cpu_limit_usage", "cpu usage of the container in percent of its cpu limit", baseLabels, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, usage,baseLabelValues...)
 	}
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Printf("get clientset error: %s", err.Error())
-		ch <- prometheus.MustNewConstMetric(k8s_container_monitorstatus, prometheus.GaugeValue, float64(0))
-		return
+	var containerstate = 3
+	if status.State.Waiting !=nil {
+		containerstate = 0
+	}else {
+		if status.State.Running !=nil {
+			containerstate = 1
+		}else {
+			containerstate = 2
+		}
 	}
-	pod, err := clientset.CoreV1().Pods(podnamespace).Get(podname,metav1.GetOptions{})
-	if err != nil {
-		log.Printf("get pods list error: %s", err.Error())
-		ch <- prometheus.MustNewConstMetric(k8s_container_monitorstatus, prometheus.GaugeValue, float64(0))
-		return
+	restartcount := status.RestartCount
+	container_state := prometheus.NewDesc("k8s_container_state", "container state,0:wating,1:runing,2:terminated", baseLabels, nil)
+	ch <- prometheus.MustNewConstMetric(container_state, prometheus.GaugeValue, float64(containerstate),baseLabelValues...)
+	container_restart := prometheus.NewDesc("k8s_container_restart", "container restart times", baseLabels, nil)
+	ch <- prometheus.MustNewConstMetric(container_restart, prometheus.GaugeValue, float64(restartcount),baseLabelValues...)
+	collectLastTermination(ch,status,baseLabels,baseLabelValues)
+	if container := podContainer(pod,status.Name);container != nil {
+		collectContainerResources(ch,container,baseLabels,baseLabelValues)
 	}
-	containerstatus := pod.Status.ContainerStatuses
-	for _,v := range containerstatus{
-		if v.Name == containername {
-			var containerstate = 3
-			if v.State.Waiting !=nil {
-				containerstate = 0
-			}else {
-				if v.State.Running !=nil {
-					containerstate = 1
-				}else {
-					containerstate = 2
-				}
-			}
-			restartcount := v.RestartCount
-			container_state := prometheus.NewDesc("k8s_container_state", "container state,0:wating,1:runing,2:terminated", baseLabels, nil)
-			ch <- prometheus.MustNewConstMetric(container_state, prometheus.GaugeValue, float64(containerstate),baseLabelValues...)
-			container_restart := prometheus.NewDesc("k8s_container_restart", "container restart times", baseLabels, nil)
-			ch <- prometheus.MustNewConstMetric(container_restart, prometheus.GaugeValue, float64(restartcount),baseLabelValues...)
-			break
+	collectContainerStats(ch,stats,minfo,baseLabels,baseLabelValues)
+}
+
+// cpuLimitUsage computes the cpu usage between the last two samples as a
+// percentage of the cfs quota, the same way k8s_node_cpu_usage relates the
+// node usage to its cores. It fails without a quota or a second sample.
+func cpuLimitUsage(cinfo *v1.ContainerInfo) (float64,bool) {
+	length := len(cinfo.Stats)
+	if !cinfo.Spec.HasCpu || cinfo.Spec.Cpu.Quota == 0 || cinfo.Spec.Cpu.Period == 0 || length < 2 {
+		return 0,false
+	}
+	latest := cinfo.Stats[length-1]
+	secondlatest := cinfo.Stats[length-2]
+	deltatime := latest.Timestamp.UnixNano() - secondlatest.Timestamp.UnixNano()
+	if deltatime <= 0 || latest.Cpu.Usage.Total < secondlatest.Cpu.Usage.Total {
+		return 0,false
+	}
+	deltacputime := float64(latest.Cpu.Usage.Total - secondlatest.Cpu.Usage.Total)
+	cores := float64(cinfo.Spec.Cpu.Quota) / float64(cinfo.Spec.Cpu.Period)
+	return 100*deltacputime / (cores*float64(deltatime)),true
+}
+
+// containerResources are the resources of the pod spec exported as requests
+// and limits.
+var containerResources = []apiv1.ResourceName{apiv1.ResourceCPU,apiv1.ResourceMemory,"ephemeral-storage"}
+
+// collectContainerResources exports the requests and limits of the pod spec,
+// cpu in cores and the others in bytes.
+func collectContainerResources(ch chan<-prometheus.Metric,container *apiv1.Container,baseLabels []string,baseLabelValues []string) {
+	requests := prometheus.NewDesc("k8s_container_resource_requests", "resources requested by the container in the pod spec, cpu in cores and others in bytes", append(baseLabels,"resource"), nil)
+	limits := prometheus.NewDesc("k8s_container_resource_limits", "resource limits of the container in the pod spec, cpu in cores and others in bytes", append(baseLabels,"resource"), nil)
+	for _,name := range containerResources{
+		if q,ok := container.Resources.Requests[name];ok {
+			ch <- prometheus.MustNewConstMetric(requests, prometheus.GaugeValue, quantityValue(name,q),append(baseLabelValues,string(name))...)
+		}
+		if q,ok := container.Resources.Limits[name];ok {
+			ch <- prometheus.MustNewConstMetric(limits, prometheus.GaugeValue, quantityValue(name,q),append(baseLabelValues,string(name))...)
+		}
+	}
+}
+
+func quantityValue(name apiv1.ResourceName,q resource.Quantity) float64 {
+	if name == apiv1.ResourceCPU {
+		return float64(q.MilliValue())/1000
+	}
+	return float64(q.Value())
+}
+
+// podContainer returns the spec of the named container of the pod, or nil.
+func podContainer(pod *apiv1.Pod,name string) *apiv1.Container {
+	for i := range pod.Spec.Containers{
+		if pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i]
 		}
 	}
+	return nil
+}
+
+// collectLastTermination exports how the previous instance of the container
+// ended, most importantly whether it was killed by the OOM killer.
+func collectLastTermination(ch chan<-prometheus.Metric,status *apiv1.ContainerStatus,baseLabels []string,baseLabelValues []string) {
+	oomKilled := 0
+	terminated := status.LastTerminationState.Terminated
+	if terminated != nil && terminated.Reason == "OOMKilled" {
+		oomKilled = 1
+	}
+	desc := prometheus.NewDesc("k8s_container_oom_killed", "whether the last termination of the container was an OOM kill", baseLabels, nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(oomKilled),baseLabelValues...)
+	if terminated == nil {
+		return
+	}
+	desc = prometheus.NewDesc("k8s_container_last_terminated_reason", "reason of the last termination of the container", append(baseLabels,"reason"), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1,append(baseLabelValues,terminated.Reason)...)
+	desc = prometheus.NewDesc("k8s_container_last_terminated_exit_code", "exit code of the last termination of the container", baseLabels, nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(terminated.ExitCode),baseLabelValues...)
+	if !terminated.FinishedAt.IsZero() {
+		desc = prometheus.NewDesc("k8s_container_last_terminated_timestamp_seconds", "finish time of the last termination of the container since unix epoch in seconds", baseLabels, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(terminated.FinishedAt.Unix()),baseLabelValues...)
+	}
+}
+
+// collectContainerStats emits containerMetrics and diskIoMetrics of one
+// stats sample.
+func collectContainerStats(ch chan<-prometheus.Metric,stats *v1.ContainerStats,minfo *v1.MachineInfo,baseLabels []string,baseLabelValues []string) {
 	for _,cm := range containerMetrics{
 		desc := cm.desc(baseLabels)
 		for _,metricValue := range cm.getValues(stats){
-			ch<-prometheus.MustNewConstMetric(desc,prometheus.GaugeValue,float64(metricValue.value),append(baseLabels,metricValue.labels...)...)
+			ch<-prometheus.MustNewConstMetric(desc,cm.valueType,float64(metricValue.value),append(baseLabelValues,metricValue.labels...)...)
 		}
 	}
-	ch<-prometheus.MustNewConstMetric(k8s_container_monitorstatus,prometheus.GaugeValue,float64(1))
+	for _,dm := range diskIoMetrics{
+		desc := prometheus.NewDesc(dm.name,dm.help,append(baseLabels,"device","operation"),nil)
+		for _,disk := range dm.getStats(&stats.DiskIo){
+			device := diskName(minfo,disk.Major,disk.Minor)
+			for _,op := range diskIoOperations{
+				value,ok := disk.Stats[op]
+				if !ok {
+					continue
+				}
+				ch<-prometheus.MustNewConstMetric(desc,dm.valueType,float64(value)*dm.scale,append(baseLabelValues,device,op)...)
+			}
+		}
+	}
+}
+
+type diskIoMetric struct{
+	name string
+	help string
+	valueType prometheus.ValueType
+	scale float64
+	getStats func(s *v1.DiskIoStats) []v1.PerDiskStats
+}
+
+// diskIoOperations are the blkio operation keys exported as the operation
+// label; Total is left out as it is their sum.
+var diskIoOperations = []string{"Read","Write","Sync","Async"}
+
+var diskIoMetrics = []diskIoMetric{
+	{
+		name:"k8s_container_blkio_service_bytes_total",
+		help:"Cumulative count of bytes transferred to and from the device",
+		valueType:prometheus.CounterValue,
+		scale:1,
+		getStats: func(s *v1.DiskIoStats) []v1.PerDiskStats {
+			return s.IoServiceBytes
+		},
+	},{
+		name:"k8s_container_blkio_serviced_total",
+		help:"Cumulative count of I/O operations issued to the device",
+		valueType:prometheus.CounterValue,
+		scale:1,
+		getStats: func(s *v1.DiskIoStats) []v1.PerDiskStats {
+			return s.IoServiced
+		},
+	},{
+		name:"k8s_container_blkio_queued",
+		help:"Number of I/O operations currently queued for the device",
+		valueType:prometheus.GaugeValue,
+		scale:1,
+		getStats: func(s *v1.DiskIoStats) []v1.PerDiskStats {
+			return s.IoQueued
+		},
+	},{
+		name:"k8s_container_blkio_wait_time_seconds_total",
+		help:"Cumulative time I/O operations spent waiting in the scheduler queues in seconds",
+		valueType:prometheus.CounterValue,
+		scale:1/float64(time.Second),
+		getStats: func(s *v1.DiskIoStats) []v1.PerDiskStats {
+			return s.IoWaitTime
+		},
+	},{
+		name:"k8s_container_blkio_service_time_seconds_total",
+		help:"Cumulative time between dispatch and completion of I/O operations in seconds",
+		valueType:prometheus.CounterValue,
+		scale:1/float64(time.Second),
+		getStats: func(s *v1.DiskIoStats) []v1.PerDiskStats {
+			return s.IoServiceTime
+		},
+	},
 }
 
-func containerNameToLabels(name string) map[string]string {
-	re := regexp.MustCompile(`^k8s_(?P<kubernetes_container_name>[^_\.]+)[^_]*_(?P<kubernetes_pod_name>[^_]+)_(?P<kubernetes_namespace>[^_]+)`)
-	reCaptureNames := re.SubexpNames()
-	extraLabels := map[string]string{}
-	matches := re.FindStringSubmatch(name)
-	for i,match := range matches{
-		if len(reCaptureNames[i])>0 {
-			extraLabels[re.SubexpNames()[i]] = match
+// diskName maps a block device number to its name using the machine disk
+// map, falling back to major:minor.
+func diskName(minfo *v1.MachineInfo,major,minor uint64) string {
+	key := fmt.Sprintf("%d:%d",major,minor)
+	if minfo != nil {
+		if disk,ok := minfo.DiskMap[key];ok && disk.Name != "" {
+			return "/dev/"+disk.Name
 		}
 	}
-	return extraLabels
-}
\ No newline at end of file
+	return key
+}
+
+// findContainerStatus returns the status of the container with the given
+// (possibly shortened) ID, or nil.
+func findContainerStatus(pod *apiv1.Pod,containerID string) *apiv1.ContainerStatus {
+	if containerID == "" {
+		return nil
+	}
+	for i := range pod.Status.ContainerStatuses{
+		if strings.HasPrefix(containerIDOf(pod.Status.ContainerStatuses[i].ContainerID),containerID) {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package collectors
+
+import (
+	"log"
+	"time"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// K8sEventCollector exports counters of the events of a cluster.
+type K8sEventCollector struct {
+	Target string
+}
+
+func (c K8sEventCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+// RecentWarningWindow is how far back /k8sc and /k8sn look for warning
+// events of their pod or node.
+var RecentWarningWindow = time.Hour
+
+var (
+	k8s_events_monitorstatus = prometheus.NewDesc("k8s_events_monitorstatus", "k8s events monitor status", nil, nil)
+	k8s_events_total         = prometheus.NewDesc("k8s_events_total",
+		"events seen since the exporter started watching the cluster, deduplicated by their count",
+		[]string{"type", "reason", "kind", "namespace"}, nil)
+)
+
+func (c K8sEventCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_events_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	counts, err := clustercache.EventCounts()
+	if err != nil {
+		log.Printf("get events error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_events_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	for k, v := range counts {
+		ch <- prometheus.MustNewConstMetric(k8s_events_total, prometheus.CounterValue, v, k.Type, k.Reason, k.Kind, k.Namespace)
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_events_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+// collectRecentWarnings exports the warning events of an object last seen
+// within RecentWarningWindow, by reason. Failing to read events only loses
+// these series, the scrape goes on.
+func collectRecentWarnings(ch chan<- prometheus.Metric, clustercache *kube.ClusterCache, desc *prometheus.Desc,
+	kind, namespace, name string, labelValues []string) {
+	warnings, err := clustercache.RecentWarnings(kind, namespace, name, RecentWarningWindow)
+	if err != nil {
+		log.Printf("get warning events of %s %s/%s error: %s", kind, namespace, name, err.Error())
+		return
+	}
+	for reason, count := range warnings {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(count), append(labelValues, reason)...)
+	}
+}
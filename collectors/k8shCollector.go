@@ -0,0 +1,231 @@
+package collectors
+
+import (
+	"encoding/json"
+	"log"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/labels"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/autoscaling"
+	autoscalingv1 "k8s.io/client-go/pkg/apis/autoscaling/v1"
+)
+
+// K8sHPACollector exports the HorizontalPodAutoscalers of a cluster with the
+// workload each one scales. Namespace and Selector narrow them down and
+// default to the namespace and label_selector params of the target.
+type K8sHPACollector struct {
+	Target    string
+	Namespace string
+	Selector  string
+}
+
+func (c K8sHPACollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+var (
+	hpa_label                      = []string{"namespace", "hpa", "scale_target_kind", "scale_target_name"}
+	k8s_hpa_monitorstatus          = prometheus.NewDesc("k8s_hpa_monitorstatus", "k8s hpa monitor status", nil, nil)
+	k8s_hpa_replicas_current       = prometheus.NewDesc("k8s_hpa_replicas_current", "current number of replicas of the scale target", hpa_label, nil)
+	k8s_hpa_replicas_desired       = prometheus.NewDesc("k8s_hpa_replicas_desired", "number of replicas the autoscaler wants", hpa_label, nil)
+	k8s_hpa_replicas_min           = prometheus.NewDesc("k8s_hpa_replicas_min", "lower limit of replicas the autoscaler scales to", hpa_label, nil)
+	k8s_hpa_replicas_max           = prometheus.NewDesc("k8s_hpa_replicas_max", "upper limit of replicas the autoscaler scales to", hpa_label, nil)
+	k8s_hpa_cpu_utilization        = prometheus.NewDesc("k8s_hpa_cpu_utilization", "current average cpu utilization of the pods in percent of their request", hpa_label, nil)
+	k8s_hpa_cpu_utilization_target = prometheus.NewDesc("k8s_hpa_cpu_utilization_target", "target average cpu utilization of the pods in percent of their request", hpa_label, nil)
+	k8s_hpa_last_scale_time        = prometheus.NewDesc("k8s_hpa_last_scale_time", "last time the autoscaler scaled since unix epoch in seconds", hpa_label, nil)
+	k8s_hpa_at_max_replicas        = prometheus.NewDesc("k8s_hpa_at_max_replicas", "whether the autoscaler is held back by its maximum replicas", hpa_label, nil)
+	k8s_hpa_unable_to_scale        = prometheus.NewDesc("k8s_hpa_unable_to_scale", "whether the autoscaler can not fetch metrics or scale its target", hpa_label, nil)
+	k8s_hpa_condition              = prometheus.NewDesc("k8s_hpa_condition", "autoscaler condition, 1 when its status is True", append(hpa_label, "condition"), nil)
+)
+
+const (
+	// hpaConditionsAnnotation carries the autoscaler conditions on
+	// autoscaling/v1 objects, which have no field for them.
+	hpaConditionsAnnotation = "autoscaling.alpha.kubernetes.io/conditions"
+	hpaAbleToScale          = "AbleToScale"
+	hpaScalingActive        = "ScalingActive"
+	hpaScalingLimited       = "ScalingLimited"
+	hpaTooManyReplicas      = "TooManyReplicas"
+)
+
+type hpaCondition struct {
+	Type   string                `json:"type"`
+	Status apiv1.ConditionStatus `json:"status"`
+	Reason string                `json:"reason,omitempty"`
+}
+
+// hpaState is what /k8sh and /k8sc export about an autoscaler.
+type hpaState struct {
+	hpa           *autoscalingv1.HorizontalPodAutoscaler
+	conditions    []hpaCondition
+	atMax         bool
+	unableToScale bool
+}
+
+func newHPAState(hpa *autoscalingv1.HorizontalPodAutoscaler) hpaState {
+	s := hpaState{hpa: hpa}
+	if value, ok := hpa.Annotations[hpaConditionsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &s.conditions); err != nil {
+			log.Printf("parse conditions of hpa %s/%s error: %s", hpa.Namespace, hpa.Name, err.Error())
+		}
+	}
+	s.atMax = hpa.Spec.MaxReplicas > 0 && hpa.Status.CurrentReplicas >= hpa.Spec.MaxReplicas
+	for _, condition := range s.conditions {
+		switch {
+		case condition.Type == hpaScalingLimited && condition.Status == apiv1.ConditionTrue && condition.Reason == hpaTooManyReplicas:
+			s.atMax = true
+		case (condition.Type == hpaAbleToScale || condition.Type == hpaScalingActive) && condition.Status == apiv1.ConditionFalse:
+			s.unableToScale = true
+		}
+	}
+	return s
+}
+
+func (s hpaState) cpuTarget() int32 {
+	if s.hpa.Spec.TargetCPUUtilizationPercentage != nil {
+		return *s.hpa.Spec.TargetCPUUtilizationPercentage
+	}
+	return autoscaling.DefaultCPUUtilization
+}
+
+func (c K8sHPACollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	namespace := c.Namespace
+	if namespace == "" {
+		namespace = monitor_info.Params_maps["namespace"]
+	}
+	selectorString := c.Selector
+	if selectorString == "" {
+		selectorString = monitor_info.Params_maps["label_selector"]
+	}
+	selector, err := labels.Parse(selectorString)
+	if err != nil {
+		log.Printf("parse label selector %q error: %s", selectorString, err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	hpas, err := clustercache.HorizontalPodAutoscalers(namespace)
+	if err != nil {
+		log.Printf("get horizontalpodautoscalers error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	for _, hpa := range hpas {
+		if !selector.Matches(labels.Set(hpa.Labels)) {
+			continue
+		}
+		s := newHPAState(hpa)
+		labelValues := []string{hpa.Namespace, hpa.Name, hpa.Spec.ScaleTargetRef.Kind, hpa.Spec.ScaleTargetRef.Name}
+		minReplicas := int32(1)
+		if hpa.Spec.MinReplicas != nil {
+			minReplicas = *hpa.Spec.MinReplicas
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_replicas_current, prometheus.GaugeValue, float64(hpa.Status.CurrentReplicas), labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_replicas_desired, prometheus.GaugeValue, float64(hpa.Status.DesiredReplicas), labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_replicas_min, prometheus.GaugeValue, float64(minReplicas), labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_replicas_max, prometheus.GaugeValue, float64(hpa.Spec.MaxReplicas), labelValues...)
+		if hpa.Status.CurrentCPUUtilizationPercentage != nil {
+			ch <- prometheus.MustNewConstMetric(k8s_hpa_cpu_utilization, prometheus.GaugeValue, float64(*hpa.Status.CurrentCPUUtilizationPercentage), labelValues...)
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_cpu_utilization_target, prometheus.GaugeValue, float64(s.cpuTarget()), labelValues...)
+		if hpa.Status.LastScaleTime != nil {
+			ch <- prometheus.MustNewConstMetric(k8s_hpa_last_scale_time, prometheus.GaugeValue, float64(hpa.Status.LastScaleTime.Unix()), labelValues...)
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_hpa_at_max_replicas, prometheus.GaugeValue, boolValue(s.atMax), labelValues...)
+		// Servers older than 1.7 do not report conditions.
+		if len(s.conditions) > 0 {
+			ch <- prometheus.MustNewConstMetric(k8s_hpa_unable_to_scale, prometheus.GaugeValue, boolValue(s.unableToScale), labelValues...)
+		}
+		for _, condition := range s.conditions {
+			ch <- prometheus.MustNewConstMetric(k8s_hpa_condition, prometheus.GaugeValue, boolValue(condition.Status == apiv1.ConditionTrue),
+				append(labelValues, condition.Type)...)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_hpa_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+// collectPodHPA exports the autoscaler scaling the workload a pod belongs to,
+// so /k8sc shows whether the container is about to be scaled out. It never
+// waits for autoscalers or ReplicaSets to be listed: until they are, or when
+// they cannot be, only these series are lost and the scrape goes on.
+func collectPodHPA(ch chan<- prometheus.Metric, clustercache *kube.ClusterCache, pod *apiv1.Pod) {
+	hpas, err := clustercache.HorizontalPodAutoscalersIfSynced(pod.Namespace)
+	if err != nil {
+		log.Printf("get horizontalpodautoscalers of %s error: %s", pod.Namespace, err.Error())
+		return
+	}
+	if len(hpas) == 0 {
+		return
+	}
+	kind, name, err := podScaleTarget(clustercache, pod)
+	if err != nil {
+		log.Printf("get scale target of pod %s/%s error: %s", pod.Namespace, pod.Name, err.Error())
+		return
+	}
+	labelNames := []string{"kubernetes_pod_name", "kubernetes_namespace", "hpa"}
+	for _, hpa := range hpas {
+		if kind == "" || hpa.Spec.ScaleTargetRef.Kind != kind || hpa.Spec.ScaleTargetRef.Name != name {
+			continue
+		}
+		s := newHPAState(hpa)
+		labelValues := []string{pod.Name, pod.Namespace, hpa.Name}
+		desc := prometheus.NewDesc("k8s_container_hpa_replicas_current", "current replicas of the autoscaler scaling the pod of the container", labelNames, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(hpa.Status.CurrentReplicas), labelValues...)
+		desc = prometheus.NewDesc("k8s_container_hpa_replicas_max", "maximum replicas of the autoscaler scaling the pod of the container", labelNames, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(hpa.Spec.MaxReplicas), labelValues...)
+		desc = prometheus.NewDesc("k8s_container_hpa_at_max_replicas", "whether the autoscaler scaling the pod of the container is at its maximum replicas", labelNames, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolValue(s.atMax), labelValues...)
+		if hpa.Status.CurrentCPUUtilizationPercentage != nil {
+			desc = prometheus.NewDesc("k8s_container_hpa_cpu_utilization", "current cpu utilization the autoscaler scaling the pod of the container sees in percent", labelNames, nil)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(*hpa.Status.CurrentCPUUtilizationPercentage), labelValues...)
+		}
+		desc = prometheus.NewDesc("k8s_container_hpa_cpu_utilization_target", "cpu utilization the autoscaler scaling the pod of the container aims for in percent", labelNames, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(s.cpuTarget()), labelValues...)
+	}
+}
+
+// podScaleTarget returns the kind and name of the object an autoscaler would
+// scale to change the replicas of pod: its controller, or the Deployment
+// owning its ReplicaSet. kind is "" for pods nothing scales.
+func podScaleTarget(clustercache *kube.ClusterCache, pod *apiv1.Pod) (string, string, error) {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		if ref.Kind != kindReplicaSet {
+			return ref.Kind, ref.Name, nil
+		}
+		replicasets, err := clustercache.ReplicaSetsIfSynced(pod.Namespace)
+		if err != nil {
+			return "", "", err
+		}
+		for _, rs := range replicasets {
+			if rs.UID != ref.UID {
+				continue
+			}
+			for _, rsRef := range rs.OwnerReferences {
+				if rsRef.Controller != nil && *rsRef.Controller {
+					return rsRef.Kind, rsRef.Name, nil
+				}
+			}
+		}
+		return ref.Kind, ref.Name, nil
+	}
+	return "", "", nil
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
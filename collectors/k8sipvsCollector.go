@@ -0,0 +1,305 @@
+package collectors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// K8sIPVSCollector exports the IPVS table kube-proxy programs on the node the
+// exporter runs on, read from the host procfs at ProcPath. Virtual services
+// and backends are mapped back to the Services and Pods of the cluster named
+// by Target.
+type K8sIPVSCollector struct {
+	Target   string
+	ProcPath string
+}
+
+func (c K8sIPVSCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+var (
+	ipvs_service_label = []string{"proto", "local_address", "local_port", "local_mark", "service_namespace", "service_name", "port_name"}
+	ipvs_backend_label = append(ipvs_service_label, "remote_address", "remote_port", "pod_namespace", "pod_name")
+
+	k8s_ipvs_monitorstatus         = prometheus.NewDesc("k8s_ipvs_monitorstatus", "k8s ipvs monitor status", nil, nil)
+	k8s_ipvs_connections_total     = prometheus.NewDesc("k8s_ipvs_connections_total", "connections handled by IPVS", nil, nil)
+	k8s_ipvs_incoming_packets      = prometheus.NewDesc("k8s_ipvs_incoming_packets_total", "incoming packets handled by IPVS", nil, nil)
+	k8s_ipvs_outgoing_packets      = prometheus.NewDesc("k8s_ipvs_outgoing_packets_total", "outgoing packets handled by IPVS", nil, nil)
+	k8s_ipvs_incoming_bytes        = prometheus.NewDesc("k8s_ipvs_incoming_bytes_total", "incoming bytes handled by IPVS", nil, nil)
+	k8s_ipvs_outgoing_bytes        = prometheus.NewDesc("k8s_ipvs_outgoing_bytes_total", "outgoing bytes handled by IPVS", nil, nil)
+	k8s_ipvs_backend_active_conns  = prometheus.NewDesc("k8s_ipvs_backend_connections_active", "active connections of an IPVS backend", ipvs_backend_label, nil)
+	k8s_ipvs_backend_inactive      = prometheus.NewDesc("k8s_ipvs_backend_connections_inactive", "inactive connections of an IPVS backend", ipvs_backend_label, nil)
+	k8s_ipvs_backend_weight        = prometheus.NewDesc("k8s_ipvs_backend_weight", "weight of an IPVS backend", ipvs_backend_label, nil)
+	k8s_ipvs_service_backends      = prometheus.NewDesc("k8s_ipvs_service_backends", "IPVS backends of a virtual service with a non-zero weight", ipvs_service_label, nil)
+	k8s_ipvs_service_active_conns  = prometheus.NewDesc("k8s_ipvs_service_connections_active", "active connections of an IPVS virtual service", ipvs_service_label, nil)
+	k8s_ipvs_service_inactive      = prometheus.NewDesc("k8s_ipvs_service_connections_inactive", "inactive connections of an IPVS virtual service", ipvs_service_label, nil)
+	k8s_ipvs_service_endpoints     = prometheus.NewDesc("k8s_ipvs_service_endpoints", "ready endpoints of the Service port the virtual service belongs to", ipvs_service_label, nil)
+	k8s_ipvs_cluster_ip_programmed = prometheus.NewDesc("k8s_ipvs_cluster_ip_programmed",
+		"whether the ClusterIP of a Service port has IPVS backends on this node", []string{"proto", "cluster_ip", "port", "service_namespace", "service_name", "port_name"}, nil)
+	k8s_conntrack_entries       = prometheus.NewDesc("k8s_conntrack_entries", "entries in the conntrack table", nil, nil)
+	k8s_conntrack_entries_limit = prometheus.NewDesc("k8s_conntrack_entries_limit", "maximum size of the conntrack table", nil, nil)
+)
+
+// servicePortRef is the Service port a virtual address or backend belongs to.
+type servicePortRef struct {
+	namespace, name, port string
+	endpoints             int
+}
+
+type virtualService struct {
+	labelValues      []string
+	endpoints        int
+	backends         int
+	active, inactive uint64
+}
+
+func (c K8sIPVSCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	// The IPVS table and conntrack are per network namespace. Reading them
+	// through the init process sees the host's, not the exporter pod's.
+	fs, err := procfs.NewFS(filepath.Join(c.ProcPath, "1"))
+	if err != nil {
+		log.Printf("open host procfs error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	if err := collectConntrack(ch, fs, c.ProcPath); err != nil {
+		log.Printf("read conntrack error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	if _, err := os.Stat(fs.Path("net/ip_vs_stats")); err != nil {
+		log.Printf("read ipvs stats error: %s, is the ip_vs module loaded and kube-proxy in IPVS mode?", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	stats, err := fs.NewIPVSStats()
+	if err != nil {
+		log.Printf("read ipvs stats error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	backends, err := fs.NewIPVSBackendStatus()
+	if err != nil {
+		log.Printf("read ipvs backends error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	services, err := clustercache.Services("")
+	if err != nil {
+		log.Printf("get services list error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	endpoints, err := clustercache.Endpoints("")
+	if err != nil {
+		log.Printf("get endpoints list error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_ipvs_connections_total, prometheus.CounterValue, float64(stats.Connections))
+	ch <- prometheus.MustNewConstMetric(k8s_ipvs_incoming_packets, prometheus.CounterValue, float64(stats.IncomingPackets))
+	ch <- prometheus.MustNewConstMetric(k8s_ipvs_outgoing_packets, prometheus.CounterValue, float64(stats.OutgoingPackets))
+	ch <- prometheus.MustNewConstMetric(k8s_ipvs_incoming_bytes, prometheus.CounterValue, float64(stats.IncomingBytes))
+	ch <- prometheus.MustNewConstMetric(k8s_ipvs_outgoing_bytes, prometheus.CounterValue, float64(stats.OutgoingBytes))
+
+	virtualIPs, nodePorts := serviceAddresses(services, endpoints)
+	podsByEndpoint := endpointPods(endpoints)
+	virtuals := map[string]*virtualService{}
+	var order []string
+	for _, b := range backends {
+		localAddress := ""
+		if b.LocalAddress != nil {
+			localAddress = b.LocalAddress.String()
+		}
+		localPort := strconv.Itoa(int(b.LocalPort))
+		key := ipvsKey(b.Proto, localAddress, localPort) + "/" + b.LocalMark
+		vs, ok := virtuals[key]
+		if !ok {
+			ref, found := virtualIPs[ipvsKey(b.Proto, localAddress, localPort)]
+			if !found && b.LocalMark == "" {
+				ref, found = nodePorts[ipvsKey(b.Proto, "", localPort)]
+			}
+			vs = &virtualService{labelValues: []string{b.Proto, localAddress, localPort, b.LocalMark, ref.namespace, ref.name, ref.port}, endpoints: ref.endpoints}
+			if !found {
+				vs.endpoints = -1
+			}
+			virtuals[key] = vs
+			order = append(order, key)
+		}
+		if b.Weight > 0 {
+			vs.backends++
+		}
+		vs.active += b.ActiveConn
+		vs.inactive += b.InactConn
+
+		remotePort := strconv.Itoa(int(b.RemotePort))
+		pod := podsByEndpoint[ipvsKey(b.Proto, b.RemoteAddress.String(), remotePort)]
+		labelValues := append(append([]string{}, vs.labelValues...), b.RemoteAddress.String(), remotePort, pod.namespace, pod.name)
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_backend_active_conns, prometheus.GaugeValue, float64(b.ActiveConn), labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_backend_inactive, prometheus.GaugeValue, float64(b.InactConn), labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_backend_weight, prometheus.GaugeValue, float64(b.Weight), labelValues...)
+	}
+	for _, key := range order {
+		vs := virtuals[key]
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_service_backends, prometheus.GaugeValue, float64(vs.backends), vs.labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_service_active_conns, prometheus.GaugeValue, float64(vs.active), vs.labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_ipvs_service_inactive, prometheus.GaugeValue, float64(vs.inactive), vs.labelValues...)
+		if vs.endpoints >= 0 {
+			ch <- prometheus.MustNewConstMetric(k8s_ipvs_service_endpoints, prometheus.GaugeValue, float64(vs.endpoints), vs.labelValues...)
+		}
+	}
+	// The backend list only has virtual services with at least one backend,
+	// so a ClusterIP kube-proxy failed to program shows up here as 0.
+	for _, service := range services {
+		if !hasClusterIP(service) {
+			continue
+		}
+		for _, port := range service.Spec.Ports {
+			portNumber := strconv.Itoa(int(port.Port))
+			vs, ok := virtuals[ipvsKey(string(port.Protocol), service.Spec.ClusterIP, portNumber)+"/"]
+			programmed := 0.0
+			if ok && vs.backends > 0 {
+				programmed = 1
+			}
+			ch <- prometheus.MustNewConstMetric(k8s_ipvs_cluster_ip_programmed, prometheus.GaugeValue, programmed,
+				string(port.Protocol), service.Spec.ClusterIP, portNumber, service.Namespace, service.Name, port.Name)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_ipvs_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+func ipvsKey(proto, address, port string) string {
+	return proto + "/" + net.JoinHostPort(address, port)
+}
+
+func hasClusterIP(service *apiv1.Service) bool {
+	return service.Spec.ClusterIP != "" && service.Spec.ClusterIP != apiv1.ClusterIPNone
+}
+
+// serviceAddresses maps the addresses kube-proxy creates virtual services for,
+// ClusterIP, external IPs and load balancer IPs, to their Service port. Node
+// ports are keyed without an address since they are bound to every node IP.
+func serviceAddresses(services []*apiv1.Service, endpoints []*apiv1.Endpoints) (map[string]servicePortRef, map[string]servicePortRef) {
+	ready := map[string]int{}
+	for _, ep := range endpoints {
+		for _, subset := range ep.Subsets {
+			for _, port := range subset.Ports {
+				ready[ep.Namespace+"/"+ep.Name+"/"+port.Name] += len(subset.Addresses)
+			}
+		}
+	}
+	virtualIPs := map[string]servicePortRef{}
+	nodePorts := map[string]servicePortRef{}
+	for _, service := range services {
+		var addresses []string
+		if hasClusterIP(service) {
+			addresses = append(addresses, service.Spec.ClusterIP)
+		}
+		addresses = append(addresses, service.Spec.ExternalIPs...)
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				addresses = append(addresses, ingress.IP)
+			}
+		}
+		for _, port := range service.Spec.Ports {
+			ref := servicePortRef{service.Namespace, service.Name, port.Name, ready[service.Namespace+"/"+service.Name+"/"+port.Name]}
+			portNumber := strconv.Itoa(int(port.Port))
+			for _, address := range addresses {
+				virtualIPs[ipvsKey(string(port.Protocol), address, portNumber)] = ref
+			}
+			if port.NodePort != 0 {
+				nodePorts[ipvsKey(string(port.Protocol), "", strconv.Itoa(int(port.NodePort)))] = ref
+			}
+		}
+	}
+	return virtualIPs, nodePorts
+}
+
+// endpointPods maps every endpoint address and port to the pod behind it.
+func endpointPods(endpoints []*apiv1.Endpoints) map[string]servicePortRef {
+	pods := map[string]servicePortRef{}
+	for _, ep := range endpoints {
+		for _, subset := range ep.Subsets {
+			addresses := append(append([]apiv1.EndpointAddress{}, subset.Addresses...), subset.NotReadyAddresses...)
+			for _, address := range addresses {
+				if address.TargetRef == nil || address.TargetRef.Kind != "Pod" {
+					continue
+				}
+				for _, port := range subset.Ports {
+					pods[ipvsKey(string(port.Protocol), address.IP, strconv.Itoa(int(port.Port)))] = servicePortRef{
+						namespace: address.TargetRef.Namespace, name: address.TargetRef.Name}
+				}
+			}
+		}
+	}
+	return pods
+}
+
+// collectConntrack reads the entries of the host conntrack table from the
+// per-cpu stats of hostFS, since /proc/sys only shows the exporter's network
+// namespace. nf_conntrack_max is global and read from procPath.
+func collectConntrack(ch chan<- prometheus.Metric, hostFS procfs.FS, procPath string) error {
+	count, err := readConntrackEntries(hostFS.Path("net/stat/nf_conntrack"))
+	if os.IsNotExist(err) {
+		// nf_conntrack is not loaded.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	max, err := readProcUint(filepath.Join(procPath, "sys/net/netfilter/nf_conntrack_max"))
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_conntrack_entries, prometheus.GaugeValue, float64(count))
+	ch <- prometheus.MustNewConstMetric(k8s_conntrack_entries_limit, prometheus.GaugeValue, float64(max))
+	return nil
+}
+
+// readConntrackEntries returns the entries column of net/stat/nf_conntrack.
+// Every cpu line repeats the same table wide count, in hex.
+func readConntrackEntries(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("no cpu lines in %s", path)
+	}
+	header, values := strings.Fields(lines[0]), strings.Fields(lines[1])
+	for i, name := range header {
+		if name == "entries" && i < len(values) {
+			return strconv.ParseUint(values[i], 16, 64)
+		}
+	}
+	return 0, fmt.Errorf("no entries column in %s", path)
+}
+
+func readProcUint(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
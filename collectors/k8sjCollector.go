@@ -0,0 +1,216 @@
+package collectors
+
+import (
+	"log"
+	"time"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/labels"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+	batchv2alpha1 "k8s.io/client-go/pkg/apis/batch/v2alpha1"
+)
+
+// K8sJobCollector exports the Jobs and CronJobs of a cluster. Namespace and
+// Selector narrow them down and default to the namespace and label_selector
+// params of the target.
+type K8sJobCollector struct {
+	Target    string
+	Namespace string
+	Selector  string
+}
+
+func (c K8sJobCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+var (
+	job_label                      = []string{"namespace", "job", "cronjob"}
+	k8s_job_monitorstatus          = prometheus.NewDesc("k8s_job_monitorstatus", "k8s job monitor status", nil, nil)
+	k8s_job_active                 = prometheus.NewDesc("k8s_job_active", "number of actively running pods of the job", job_label, nil)
+	k8s_job_succeeded              = prometheus.NewDesc("k8s_job_succeeded", "number of pods of the job that succeeded", job_label, nil)
+	k8s_job_failed                 = prometheus.NewDesc("k8s_job_failed", "number of pods of the job that failed", job_label, nil)
+	k8s_job_completions            = prometheus.NewDesc("k8s_job_completions", "number of successful pods the job needs", job_label, nil)
+	k8s_job_parallelism            = prometheus.NewDesc("k8s_job_parallelism", "maximum number of pods of the job running at once", job_label, nil)
+	k8s_job_start_time             = prometheus.NewDesc("k8s_job_start_time", "job start time since unix epoch in seconds", job_label, nil)
+	k8s_job_completion_time        = prometheus.NewDesc("k8s_job_completion_time", "job completion time since unix epoch in seconds", job_label, nil)
+	k8s_job_duration_seconds       = prometheus.NewDesc("k8s_job_duration_seconds", "seconds from the start of the job to its completion, or to now while it runs", job_label, nil)
+	k8s_job_condition              = prometheus.NewDesc("k8s_job_condition", "job condition, 1 when its status is True", append(job_label, "condition"), nil)
+	k8s_job_deadline_exceeded      = prometheus.NewDesc("k8s_job_deadline_exceeded", "whether the job failed for running past activeDeadlineSeconds", job_label, nil)
+	k8s_job_cronjob_monitorstatus  = prometheus.NewDesc("k8s_job_cronjob_monitorstatus", "whether the cronjobs of the cluster could be read", nil, nil)
+	cronjob_label                  = []string{"namespace", "cronjob"}
+	k8s_cronjob_info               = prometheus.NewDesc("k8s_cronjob_info", "cronjob schedule and concurrency policy", append(cronjob_label, "schedule", "concurrency_policy"), nil)
+	k8s_cronjob_suspended          = prometheus.NewDesc("k8s_cronjob_suspended", "whether the cronjob is suspended", cronjob_label, nil)
+	k8s_cronjob_active_jobs        = prometheus.NewDesc("k8s_cronjob_active_jobs", "number of running jobs of the cronjob", cronjob_label, nil)
+	k8s_cronjob_last_schedule_time = prometheus.NewDesc("k8s_cronjob_last_schedule_time", "last time the cronjob was scheduled since unix epoch in seconds", cronjob_label, nil)
+	k8s_cronjob_next_schedule_time = prometheus.NewDesc("k8s_cronjob_next_schedule_time", "next time the cronjob is due after its last schedule since unix epoch in seconds", cronjob_label, nil)
+	k8s_cronjob_missed_schedule    = prometheus.NewDesc("k8s_cronjob_missed_schedule", "whether the cronjob was not scheduled at a time it was due", cronjob_label, nil)
+	k8s_cronjob_schedule_valid     = prometheus.NewDesc("k8s_cronjob_schedule_valid", "whether the exporter could parse the schedule of the cronjob", cronjob_label, nil)
+)
+
+const (
+	// deadlineExceededReason is the Failed condition reason of a job that
+	// ran past activeDeadlineSeconds.
+	deadlineExceededReason = "DeadlineExceeded"
+	// cronJobMissedGrace is how late a cronjob may be scheduled before it
+	// counts as missed. The controller only checks every 10 seconds.
+	cronJobMissedGrace = time.Minute
+)
+
+func (c K8sJobCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	namespace := c.Namespace
+	if namespace == "" {
+		namespace = monitor_info.Params_maps["namespace"]
+	}
+	selectorString := c.Selector
+	if selectorString == "" {
+		selectorString = monitor_info.Params_maps["label_selector"]
+	}
+	selector, err := labels.Parse(selectorString)
+	if err != nil {
+		log.Printf("parse label selector %q error: %s", selectorString, err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_job_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_job_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	jobs, err := clustercache.Jobs(namespace)
+	if err != nil {
+		log.Printf("get jobs error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_job_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	now := time.Now()
+	for _, job := range jobs {
+		if !selector.Matches(labels.Set(job.Labels)) {
+			continue
+		}
+		collectJob(ch, job, now)
+	}
+
+	// Clusters without batch/v2alpha1 still get their jobs exported.
+	cronjobs, err := clustercache.CronJobs(namespace)
+	if err != nil {
+		log.Printf("get cronjobs error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_job_cronjob_monitorstatus, prometheus.GaugeValue, float64(0))
+	} else {
+		for _, cronjob := range cronjobs {
+			if !selector.Matches(labels.Set(cronjob.Labels)) {
+				continue
+			}
+			collectCronJob(ch, cronjob, now)
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_job_cronjob_monitorstatus, prometheus.GaugeValue, float64(1))
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_job_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+func collectJob(ch chan<- prometheus.Metric, job *batchv1.Job, now time.Time) {
+	labelValues := []string{job.Namespace, job.Name, jobCronJob(job)}
+	ch <- prometheus.MustNewConstMetric(k8s_job_active, prometheus.GaugeValue, float64(job.Status.Active), labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_job_succeeded, prometheus.GaugeValue, float64(job.Status.Succeeded), labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_job_failed, prometheus.GaugeValue, float64(job.Status.Failed), labelValues...)
+	if job.Spec.Completions != nil {
+		ch <- prometheus.MustNewConstMetric(k8s_job_completions, prometheus.GaugeValue, float64(*job.Spec.Completions), labelValues...)
+	}
+	if job.Spec.Parallelism != nil {
+		ch <- prometheus.MustNewConstMetric(k8s_job_parallelism, prometheus.GaugeValue, float64(*job.Spec.Parallelism), labelValues...)
+	}
+	if job.Status.CompletionTime != nil {
+		ch <- prometheus.MustNewConstMetric(k8s_job_completion_time, prometheus.GaugeValue, float64(job.Status.CompletionTime.Unix()), labelValues...)
+	}
+	// Only jobs that succeed get a completion time, a failed one ends when
+	// its Failed condition is set.
+	var end time.Time
+	if job.Status.CompletionTime != nil {
+		end = job.Status.CompletionTime.Time
+	}
+	deadlineExceeded := 0.0
+	for _, condition := range job.Status.Conditions {
+		value := 0.0
+		if condition.Status == apiv1.ConditionTrue {
+			value = 1
+		}
+		if condition.Type == batchv1.JobFailed && value == 1 {
+			if end.IsZero() {
+				end = condition.LastTransitionTime.Time
+			}
+			if condition.Reason == deadlineExceededReason {
+				deadlineExceeded = 1
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_job_condition, prometheus.GaugeValue, value, append(labelValues, string(condition.Type))...)
+	}
+	if job.Status.StartTime != nil {
+		ch <- prometheus.MustNewConstMetric(k8s_job_start_time, prometheus.GaugeValue, float64(job.Status.StartTime.Unix()), labelValues...)
+		if end.IsZero() {
+			end = now
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_job_duration_seconds, prometheus.GaugeValue, end.Sub(job.Status.StartTime.Time).Seconds(), labelValues...)
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_job_deadline_exceeded, prometheus.GaugeValue, deadlineExceeded, labelValues...)
+}
+
+// jobCronJob returns the name of the CronJob that created a job, or "".
+func jobCronJob(job *batchv1.Job) string {
+	for _, ref := range job.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller && (ref.Kind == "CronJob" || ref.Kind == "ScheduledJob") {
+			return ref.Name
+		}
+	}
+	return ""
+}
+
+func collectCronJob(ch chan<- prometheus.Metric, cronjob *batchv2alpha1.CronJob, now time.Time) {
+	labelValues := []string{cronjob.Namespace, cronjob.Name}
+	ch <- prometheus.MustNewConstMetric(k8s_cronjob_info, prometheus.GaugeValue, 1,
+		append(labelValues, cronjob.Spec.Schedule, string(cronjob.Spec.ConcurrencyPolicy))...)
+	suspended := 0.0
+	if cronjob.Spec.Suspend != nil && *cronjob.Spec.Suspend {
+		suspended = 1
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_cronjob_suspended, prometheus.GaugeValue, suspended, labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_cronjob_active_jobs, prometheus.GaugeValue, float64(len(cronjob.Status.Active)), labelValues...)
+	last := cronjob.CreationTimestamp
+	if cronjob.Status.LastScheduleTime != nil {
+		last = *cronjob.Status.LastScheduleTime
+		ch <- prometheus.MustNewConstMetric(k8s_cronjob_last_schedule_time, prometheus.GaugeValue, float64(last.Unix()), labelValues...)
+	}
+	schedule, err := parseCronSchedule(cronjob.Spec.Schedule)
+	if err != nil {
+		log.Printf("parse schedule of cronjob %s/%s error: %s", cronjob.Namespace, cronjob.Name, err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_cronjob_schedule_valid, prometheus.GaugeValue, 0, labelValues...)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_cronjob_schedule_valid, prometheus.GaugeValue, 1, labelValues...)
+	next := schedule.next(last.Time)
+	if next.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_cronjob_next_schedule_time, prometheus.GaugeValue, float64(next.Unix()), labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_cronjob_missed_schedule, prometheus.GaugeValue, cronJobMissed(cronjob, next, now, suspended == 1), labelValues...)
+}
+
+// cronJobMissed tells whether a cronjob is past the time it was next due
+// without being scheduled. A suspended cronjob never misses, and one with
+// startingDeadlineSeconds may still be started until the deadline passes.
+func cronJobMissed(cronjob *batchv2alpha1.CronJob, next, now time.Time, suspended bool) float64 {
+	if suspended {
+		return 0
+	}
+	grace := cronJobMissedGrace
+	if d := cronjob.Spec.StartingDeadlineSeconds; d != nil && time.Duration(*d)*time.Second > grace {
+		grace = time.Duration(*d) * time.Second
+	}
+	if now.After(next.Add(grace)) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	batchv2alpha1 "k8s.io/client-go/pkg/apis/batch/v2alpha1"
+)
+
+func TestCronJobMissed(t *testing.T) {
+	next := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	deadline := func(seconds int64) *int64 { return &seconds }
+	tests := []struct {
+		name      string
+		deadline  *int64
+		suspended bool
+		late      time.Duration
+		want      float64
+	}{
+		{"not due yet", nil, false, -time.Minute, 0},
+		{"within grace", nil, false, 30 * time.Second, 0},
+		{"past grace", nil, false, 61 * time.Second, 1},
+		{"suspended", nil, true, time.Hour, 0},
+		{"short deadline keeps grace", deadline(10), false, 30 * time.Second, 0},
+		{"short deadline past grace", deadline(10), false, 61 * time.Second, 1},
+		{"long deadline not passed", deadline(300), false, 2 * time.Minute, 0},
+		{"long deadline passed", deadline(300), false, 301 * time.Second, 1},
+	}
+	for _, tt := range tests {
+		cronjob := &batchv2alpha1.CronJob{}
+		cronjob.Spec.StartingDeadlineSeconds = tt.deadline
+		if got := cronJobMissed(cronjob, next, next.Add(tt.late), tt.suspended); got != tt.want {
+			t.Errorf("%s: cronJobMissed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"container-exporter/config"
 	"log"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/kubernetes"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"github.com/google/cadvisor/client/v2"
+	"container-exporter/collectors/kube"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	"regexp"
+	"strings"
 )
 
 type K8sNodeCollector struct {
@@ -26,33 +26,42 @@ var (
 	k8s_node_status = prometheus.NewDesc("k8s_node_status", "k8s node status", node_label, nil)
 	k8s_node_container_total = prometheus.NewDesc("k8s_node_container_total", "k8s node containers in total", node_label, nil)
 	k8s_node_uptime = prometheus.NewDesc("k8s_node_uptime", "k8s node up time", node_label, nil)
+	k8s_node_warning_events = prometheus.NewDesc("k8s_node_warning_events", "recent warning events of the node by reason", append(node_label, "reason"), nil)
+	k8s_node_condition = prometheus.NewDesc("k8s_node_condition", "k8s node condition, 1 for the current status", append(node_label, "condition", "status"), nil)
+	k8s_node_condition_last_transition_time = prometheus.NewDesc("k8s_node_condition_last_transition_time_seconds", "last time the node condition changed since unix epoch in seconds", append(node_label, "condition"), nil)
+	k8s_node_taint = prometheus.NewDesc("k8s_node_taint", "k8s node taint", append(node_label, "key", "value", "effect"), nil)
+	k8s_node_unschedulable = prometheus.NewDesc("k8s_node_unschedulable", "whether the node is cordoned", node_label, nil)
+	k8s_node_info = prometheus.NewDesc("k8s_node_info", "k8s node system information", append(node_label, "kernel_version", "os_image",
+		"container_runtime_version", "kubelet_version", "kubeproxy_version", "operating_system", "architecture"), nil)
 
 )
 
+// NodeLabels are the node label keys copied into k8s_node_labels. The value of
+// the first one also fills the nodelabel label of every node metric.
+var NodeLabels = []string{"node"}
+
+var conditionStatuses = []apiv1.ConditionStatus{apiv1.ConditionTrue, apiv1.ConditionFalse, apiv1.ConditionUnknown}
+
 func (c K8sNodeCollector) Collect(ch chan<- prometheus.Metric) {
 	monitor_info := config.GetMonitorInfo(c.Target)
-	master_IP := monitor_info.Params_maps["master_ip"]
 	nodeIp := monitor_info.Params_maps["node_ip"]
-	aport := monitor_info.Params_maps["api_port"]
 	nodename := monitor_info.Params_maps["node_name"]
-	cport := monitor_info.Params_maps["cadvisor_port"]
-	endpoint := master_IP + ":" + aport
-	config := &rest.Config{
-		Host: "http://" + endpoint,
-	}
-	clientset, err := kubernetes.NewForConfig(config)
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
 	if err != nil {
-		log.Printf("get clientset error: %s", err.Error())
+		log.Printf("get cluster cache error: %s", err.Error())
 		ch <- prometheus.MustNewConstMetric(k8s_node_monitorstatus, prometheus.GaugeValue, float64(0))
 		return
 	}
-	node, err := clientset.CoreV1().Nodes().Get(nodename, metav1.GetOptions{})
+	node, err := clustercache.Node(nodename)
 	if err != nil {
 		log.Printf("get node error: %s", err.Error())
 		ch <- prometheus.MustNewConstMetric(k8s_node_monitorstatus, prometheus.GaugeValue, float64(0))
 		return
 	}
-	label := node.Labels["node"]
+	var label string
+	if len(NodeLabels) > 0 {
+		label = node.Labels[NodeLabels[0]]
+	}
 	var status =""
 	for _,v := range node.Status.Conditions{
 		if v.Type == "Ready" {
@@ -66,63 +75,99 @@ func (c K8sNodeCollector) Collect(ch chan<- prometheus.Metric) {
 	}else {
 		statuscode = 0
 	}
-	pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+	pods, err := clustercache.PodsOnNode(nodename)
 	if err != nil {
 		log.Printf("get pods list error: %s", err.Error())
 		ch <- prometheus.MustNewConstMetric(k8s_node_monitorstatus, prometheus.GaugeValue, float64(0))
 		return
 	}
 	var containercount = 0
-	for _,v := range pods.Items{
-		if v.Spec.NodeName == nodename {
-			cons := v.Spec.Containers
-			containercount = containercount+len(cons)
-		}
+	for _,v := range pods{
+		cons := v.Spec.Containers
+		containercount = containercount+len(cons)
 	}
 	createtime := node.CreationTimestamp.Unix()
 	labelvalues := []string{nodeIp, label}
 	ch <- prometheus.MustNewConstMetric(k8s_node_status, prometheus.GaugeValue, float64(statuscode),labelvalues...)
 	ch <- prometheus.MustNewConstMetric(k8s_node_container_total, prometheus.GaugeValue, float64(containercount),labelvalues...)
 	ch <- prometheus.MustNewConstMetric(k8s_node_uptime, prometheus.GaugeValue, float64(createtime),labelvalues...)
-	cadvisorendpoint := nodeIp + ":" + cport
-	client, err := v2.NewClient("http://" + cadvisorendpoint)
+	collectRecentWarnings(ch, clustercache, k8s_node_warning_events, "Node", "", nodename, labelvalues)
+	collectNodeStatus(ch, node, labelvalues)
+	usage, err := getNodeUsage(monitor_info.Params_maps, nodeIp, node)
 	if err != nil {
-		log.Printf("get client error: %s", err.Error())
+		log.Printf("get node usage error: %s", err.Error())
 		ch <- prometheus.MustNewConstMetric(k8s_node_monitorstatus, prometheus.GaugeValue, float64(0))
 		return
 	}
-	ms, err := client.MachineStats()
-	if err != nil {
-		log.Printf("get machine stats error: %s", err.Error())
-		ch <- prometheus.MustNewConstMetric(k8s_node_monitorstatus, prometheus.GaugeValue, float64(0))
-		return
-	}
-	length := len(ms)
-	latest := ms[length-1]//倒数第一个
-	secondlatest := ms[length-2]//倒数第二个
-	deltatime := latest.Timestamp.UnixNano() - secondlatest.Timestamp.UnixNano()
-	deltacputime := int64(latest.Cpu.Usage.Total - secondlatest.Cpu.Usage.Total)
-	core := node.Status.Capacity.Cpu().Value()
-	cpuusage := float64(100 * deltacputime / (core * deltatime))
-	ch <- prometheus.MustNewConstMetric(k8s_node_cpu_usage, prometheus.GaugeValue, float64(cpuusage),labelvalues...)
-	memoryused := float64(latest.Memory.Usage)
+	ch <- prometheus.MustNewConstMetric(k8s_node_cpu_usage, prometheus.GaugeValue, usage.cpuUsage,labelvalues...)
+	memoryused := usage.memoryUsed
 	ch <- prometheus.MustNewConstMetric(k8s_node_memory_used, prometheus.GaugeValue, float64(memoryused),labelvalues...)
 	totalmemory := node.Status.Capacity.Memory().Value()
 	ch <- prometheus.MustNewConstMetric(k8s_node_memory_total, prometheus.GaugeValue, float64(totalmemory),labelvalues...)
 	ch <- prometheus.MustNewConstMetric(k8s_node_memory_avlil, prometheus.GaugeValue, float64(totalmemory)-memoryused,labelvalues...)
-	fsstate := latest.Filesystem
-	node_label=append(node_label,"device")
-	k8s_node_filesystem_total:= prometheus.NewDesc("k8s_node_filesystem_total", "k8s node filesystem in total", node_label, nil)
-	k8s_node_filesystem_used := prometheus.NewDesc("k8s_node_filesystem_used", "k8s node filesystem in used", node_label, nil)
-	k8s_node_filesystem_avail := prometheus.NewDesc("k8s_node_filesystem_avail", "k8s node filesystem in avail", node_label, nil)
+	fs_label := append(append([]string{}, node_label...), "device")
+	k8s_node_filesystem_total:= prometheus.NewDesc("k8s_node_filesystem_total", "k8s node filesystem in total", fs_label, nil)
+	k8s_node_filesystem_used := prometheus.NewDesc("k8s_node_filesystem_used", "k8s node filesystem in used", fs_label, nil)
+	k8s_node_filesystem_avail := prometheus.NewDesc("k8s_node_filesystem_avail", "k8s node filesystem in avail", fs_label, nil)
+
+	for _,state := range usage.filesystems {
+		ch <- prometheus.MustNewConstMetric(k8s_node_filesystem_total, prometheus.GaugeValue, state.capacity,append(labelvalues,state.device)...)
+		ch <- prometheus.MustNewConstMetric(k8s_node_filesystem_used, prometheus.GaugeValue, state.used,append(labelvalues,state.device)...)
+		ch <- prometheus.MustNewConstMetric(k8s_node_filesystem_avail, prometheus.GaugeValue, state.avail,append(labelvalues,state.device)...)
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_node_monitorstatus, prometheus.GaugeValue, float64(1))
+}
 
-	for _,state := range fsstate {
-		capacity := state.Capacity
-		used := state.Usage
-		avail := state.Available
-		ch <- prometheus.MustNewConstMetric(k8s_node_filesystem_total, prometheus.GaugeValue, float64(*capacity),append(labelvalues,state.Device)...)
-		ch <- prometheus.MustNewConstMetric(k8s_node_filesystem_used, prometheus.GaugeValue, float64(*used),append(labelvalues,state.Device)...)
-		ch <- prometheus.MustNewConstMetric(k8s_node_filesystem_avail, prometheus.GaugeValue, float64(*avail),append(labelvalues,state.Device)...)
+// collectNodeStatus exports the conditions, taints, schedulability, system
+// info and configured labels of a node.
+func collectNodeStatus(ch chan<- prometheus.Metric, node *apiv1.Node, labelvalues []string) {
+	for _, condition := range node.Status.Conditions {
+		for _, status := range conditionStatuses {
+			value := 0.0
+			if condition.Status == status {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(k8s_node_condition, prometheus.GaugeValue, value,
+				append(labelvalues, string(condition.Type), strings.ToLower(string(status)))...)
+		}
+		if !condition.LastTransitionTime.IsZero() {
+			ch <- prometheus.MustNewConstMetric(k8s_node_condition_last_transition_time, prometheus.GaugeValue,
+				float64(condition.LastTransitionTime.Unix()), append(labelvalues, string(condition.Type))...)
+		}
 	}
-	ch <- prometheus.MustNewConstMetric(k8s_container_monitorstatus, prometheus.GaugeValue, float64(1))
+	for _, taint := range node.Spec.Taints {
+		ch <- prometheus.MustNewConstMetric(k8s_node_taint, prometheus.GaugeValue, 1,
+			append(labelvalues, taint.Key, taint.Value, string(taint.Effect))...)
+	}
+	unschedulable := 0.0
+	if node.Spec.Unschedulable {
+		unschedulable = 1
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_node_unschedulable, prometheus.GaugeValue, unschedulable, labelvalues...)
+	info := node.Status.NodeInfo
+	ch <- prometheus.MustNewConstMetric(k8s_node_info, prometheus.GaugeValue, 1, append(labelvalues, info.KernelVersion, info.OSImage,
+		info.ContainerRuntimeVersion, info.KubeletVersion, info.KubeProxyVersion, info.OperatingSystem, info.Architecture)...)
+
+	labelNames := append([]string{}, node_label...)
+	values := append([]string{}, labelvalues...)
+	seen := map[string]bool{}
+	for _, key := range NodeLabels {
+		name := "label_" + sanitizeLabelName(key)
+		if key == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		labelNames = append(labelNames, name)
+		values = append(values, node.Labels[key])
+	}
+	k8s_node_labels := prometheus.NewDesc("k8s_node_labels", "configured labels of the node", labelNames, nil)
+	ch <- prometheus.MustNewConstMetric(k8s_node_labels, prometheus.GaugeValue, 1, values...)
 }
+
+// sanitizeLabelName turns a node label key such as
+// node-role.kubernetes.io/master into a valid metric label name.
+func sanitizeLabelName(key string) string {
+	return invalidLabelChars.ReplaceAllString(key, "_")
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
@@ -0,0 +1,103 @@
+package collectors
+
+import (
+	"log"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// K8sNodeContainerCollector exports every container running on the node named
+// by node_name, reading their stats with one request to the stats source.
+type K8sNodeContainerCollector struct {
+	Target string
+}
+
+func (c K8sNodeContainerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+var (
+	k8s_node_containers_monitorstatus = prometheus.NewDesc("k8s_node_containers_monitorstatus",
+		"k8s node containers monitor status", nil, nil)
+	k8s_node_containers_scraped = prometheus.NewDesc("k8s_node_containers_scraped",
+		"number of containers of the node whose stats were exported", []string{"nodeIP"}, nil)
+	k8s_node_containers_missing = prometheus.NewDesc("k8s_node_containers_missing",
+		"number of running containers of the node the stats source does not know", []string{"nodeIP"}, nil)
+)
+
+func (c K8sNodeContainerCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	nodeIp := monitor_info.Params_maps["node_ip"]
+	nodename := monitor_info.Params_maps["node_name"]
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_node_containers_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	node, err := clustercache.Node(nodename)
+	if err != nil {
+		log.Printf("get node error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_node_containers_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	if nodeIp == "" {
+		nodeIp = nodeInternalIP(node)
+	}
+	pods, err := clustercache.PodsOnNode(nodename)
+	if err != nil {
+		log.Printf("get pods list error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_node_containers_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	source, err := newContainerSource(monitor_info.Params_maps, nodeIp, node)
+	if err != nil {
+		log.Printf("get client error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_node_containers_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	minfo, err := source.machineInfo()
+	if err != nil {
+		log.Printf("get machineinfo error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_node_containers_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	infos, err := source.containerInfos(pods)
+	if err != nil {
+		log.Printf("get containerinfos of node %s error: %s", nodename, err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_node_containers_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	machine_core := prometheus.NewDesc("k8s_container_machine_cores", "Number of CPU cores on this node",
+		[]string{"nodeIP"}, nil)
+	ch <- prometheus.MustNewConstMetric(machine_core, prometheus.GaugeValue, float64(minfo.NumCores), nodeIp)
+	machine_memory := prometheus.NewDesc("k8s_container_machine_memory", "Amount of memory installed"+
+		"on the node", []string{"nodeIP"}, nil)
+	ch <- prometheus.MustNewConstMetric(machine_memory, prometheus.GaugeValue, float64(minfo.MemoryCapacity), nodeIp)
+
+	scraped, missing := 0, 0
+	for _, pod := range pods {
+		for i := range pod.Status.ContainerStatuses {
+			status := &pod.Status.ContainerStatuses[i]
+			if status.ContainerID == "" {
+				continue
+			}
+			cinfo, ok := infos[status.ContainerID]
+			if !ok || len(cinfo.Stats) == 0 {
+				// Exited containers are gone from the stats source.
+				if status.State.Running != nil {
+					log.Printf("no stats for container %s in pod %s/%s", status.Name, pod.Namespace, pod.Name)
+					missing++
+				}
+				continue
+			}
+			collectContainer(ch, cinfo, minfo, pod, status, nodeIp)
+			scraped++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_node_containers_scraped, prometheus.GaugeValue, float64(scraped), nodeIp)
+	ch <- prometheus.MustNewConstMetric(k8s_node_containers_missing, prometheus.GaugeValue, float64(missing), nodeIp)
+	ch <- prometheus.MustNewConstMetric(k8s_node_containers_monitorstatus, prometheus.GaugeValue, float64(1))
+}
@@ -0,0 +1,176 @@
+package collectors
+
+import (
+	"log"
+	"time"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/google/cadvisor/info/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// K8sPodCollector exports every container of the pod named by pod_namespace
+// and pod_name, along with pod-level sums and status.
+type K8sPodCollector struct {
+	Target string
+}
+
+func (c K8sPodCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+var (
+	pod_label                  = []string{"nodeIP", "kubernetes_pod_name", "kubernetes_namespace"}
+	k8s_pod_monitorstatus      = prometheus.NewDesc("k8s_pod_monitorstatus", "k8s pod monitor status", nil, nil)
+	k8s_pod_phase              = prometheus.NewDesc("k8s_pod_phase", "pod phase, 1 for the current phase", append(pod_label, "phase"), nil)
+	k8s_pod_qos_class          = prometheus.NewDesc("k8s_pod_qos_class", "pod QoS class, 1 for the current class", append(pod_label, "qos_class"), nil)
+	k8s_pod_ready              = prometheus.NewDesc("k8s_pod_ready", "whether the pod is ready to serve requests", pod_label, nil)
+	k8s_pod_condition          = prometheus.NewDesc("k8s_pod_condition", "pod condition, 1 when its status is True", append(pod_label, "condition"), nil)
+	k8s_pod_containers         = prometheus.NewDesc("k8s_pod_containers", "number of containers in the pod", pod_label, nil)
+	k8s_pod_container_scraped  = prometheus.NewDesc("k8s_pod_container_scraped", "whether the stats of a container of the pod could be read", append(pod_label, "kubernetes_container_name"), nil)
+	k8s_pod_cpu_usage_seconds  = prometheus.NewDesc("k8s_pod_cpu_usage_seconds_total", "cumulative cpu time consumed by the containers of the pod in seconds", pod_label, nil)
+	k8s_pod_memory_usage_bytes = prometheus.NewDesc("k8s_pod_memory_usage_bytes", "memory usage of the containers of the pod in bytes", pod_label, nil)
+	k8s_pod_memory_working_set = prometheus.NewDesc("k8s_pod_memory_working_set_bytes", "working set of the containers of the pod in bytes", pod_label, nil)
+	k8s_pod_memory_rss         = prometheus.NewDesc("k8s_pod_memory_rss", "RSS of the containers of the pod in bytes", pod_label, nil)
+	k8s_pod_fs_usage_bytes     = prometheus.NewDesc("k8s_pod_fs_usage_bytes", "bytes consumed by the containers of the pod on their filesystems", pod_label, nil)
+	k8s_pod_restarts           = prometheus.NewDesc("k8s_pod_restarts", "restarts of the containers of the pod", pod_label, nil)
+)
+
+var podPhases = []apiv1.PodPhase{apiv1.PodPending, apiv1.PodRunning, apiv1.PodSucceeded, apiv1.PodFailed, apiv1.PodUnknown}
+
+func (c K8sPodCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	podname := monitor_info.Params_maps["pod_name"]
+	podnamespace := monitor_info.Params_maps["pod_namespace"]
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_pod_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	pod, err := clustercache.Pod(podnamespace, podname)
+	if err != nil {
+		log.Printf("get pod error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_pod_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	// node_ip is optional here, the pod may be rescheduled to another node.
+	// The status does not depend on the node, so it is emitted before the
+	// node is looked up, with an empty node IP while the pod is unscheduled.
+	nodeIp := monitor_info.Params_maps["node_ip"]
+	if nodeIp == "" {
+		nodeIp = pod.Status.HostIP
+	}
+	labelValues := []string{nodeIp, pod.Name, pod.Namespace}
+	collectPodStatus(ch, pod, labelValues)
+	if pod.Spec.NodeName == "" {
+		// Nothing runs yet, so there is no usage to read.
+		ch <- prometheus.MustNewConstMetric(k8s_pod_monitorstatus, prometheus.GaugeValue, float64(1))
+		return
+	}
+
+	node, err := clustercache.Node(pod.Spec.NodeName)
+	if err != nil {
+		log.Printf("get node error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_pod_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	sourceIp := nodeIp
+	if sourceIp == "" {
+		sourceIp = nodeInternalIP(node)
+	}
+	source, err := newContainerSource(monitor_info.Params_maps, sourceIp, node)
+	if err != nil {
+		log.Printf("get client error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_pod_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	minfo, err := source.machineInfo()
+	if err != nil {
+		log.Printf("get machineinfo error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_pod_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	var cpu, memory, workingSet, rss, fs float64
+	for i := range pod.Status.ContainerStatuses {
+		status := &pod.Status.ContainerStatuses[i]
+		scraped := 0.0
+		if status.ContainerID != "" {
+			cinfo, err := source.containerInfo(pod, status)
+			switch {
+			case err != nil:
+				log.Printf("get containerinfo of %s in pod %s/%s error: %s", status.Name, pod.Namespace, pod.Name, err.Error())
+			case len(cinfo.Stats) == 0:
+				log.Printf("no stats for container %s in pod %s/%s", status.Name, pod.Namespace, pod.Name)
+			default:
+				scraped = 1
+				collectContainer(ch, cinfo, minfo, pod, status, nodeIp)
+				stats := cinfo.Stats[len(cinfo.Stats)-1]
+				cpu += float64(stats.Cpu.Usage.Total) / float64(time.Second)
+				memory += float64(stats.Memory.Usage)
+				workingSet += float64(stats.Memory.WorkingSet)
+				rss += float64(stats.Memory.RSS)
+				fs += sumFsUsage(stats.Filesystem)
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_pod_container_scraped, prometheus.GaugeValue, scraped, append(labelValues, status.Name)...)
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_pod_cpu_usage_seconds, prometheus.CounterValue, cpu, labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_pod_memory_usage_bytes, prometheus.GaugeValue, memory, labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_pod_memory_working_set, prometheus.GaugeValue, workingSet, labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_pod_memory_rss, prometheus.GaugeValue, rss, labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_pod_fs_usage_bytes, prometheus.GaugeValue, fs, labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_pod_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+// collectPodStatus emits what the pod status says about the pod as a whole.
+func collectPodStatus(ch chan<- prometheus.Metric, pod *apiv1.Pod, labelValues []string) {
+	for _, phase := range podPhases {
+		value := 0.0
+		if pod.Status.Phase == phase {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_pod_phase, prometheus.GaugeValue, value, append(labelValues, string(phase))...)
+	}
+	if pod.Status.QOSClass != "" {
+		ch <- prometheus.MustNewConstMetric(k8s_pod_qos_class, prometheus.GaugeValue, 1, append(labelValues, string(pod.Status.QOSClass))...)
+	}
+	ready := 0.0
+	for _, condition := range pod.Status.Conditions {
+		value := 0.0
+		if condition.Status == apiv1.ConditionTrue {
+			value = 1
+		}
+		if condition.Type == apiv1.PodReady {
+			ready = value
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_pod_condition, prometheus.GaugeValue, value, append(labelValues, string(condition.Type))...)
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_pod_ready, prometheus.GaugeValue, ready, labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_pod_containers, prometheus.GaugeValue, float64(len(pod.Spec.Containers)), labelValues...)
+	restarts := 0
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += int(status.RestartCount)
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_pod_restarts, prometheus.GaugeValue, float64(restarts), labelValues...)
+}
+
+func sumFsUsage(fsStats []v1.FsStats) float64 {
+	var sum float64
+	for _, fs := range fsStats {
+		sum += float64(fs.Usage)
+	}
+	return sum
+}
+
+// nodeInternalIP returns the InternalIP address of a node, or "".
+func nodeInternalIP(node *apiv1.Node) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == apiv1.NodeInternalIP {
+			return address.Address
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package collectors
+
+import (
+	"log"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// K8sQuotaCollector exports the ResourceQuotas and LimitRanges of a
+// namespace. Namespace defaults to the namespace param of the target, and
+// when both are empty every namespace is exported.
+type K8sQuotaCollector struct {
+	Target    string
+	Namespace string
+}
+
+func (c K8sQuotaCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+var (
+	quota_label                  = []string{"namespace", "resourcequota", "resource"}
+	k8s_quota_monitorstatus      = prometheus.NewDesc("k8s_quota_monitorstatus", "k8s quota monitor status", nil, nil)
+	k8s_resourcequota_hard       = prometheus.NewDesc("k8s_resourcequota_hard", "hard limit of the quota, cpu in cores and others in bytes or objects", quota_label, nil)
+	k8s_resourcequota_used       = prometheus.NewDesc("k8s_resourcequota_used", "usage counted against the quota, cpu in cores and others in bytes or objects", quota_label, nil)
+	k8s_resourcequota_used_ratio = prometheus.NewDesc("k8s_resourcequota_used_ratio", "usage of the quota divided by its hard limit", quota_label, nil)
+	k8s_limitrange               = prometheus.NewDesc("k8s_limitrange", "limit range constraint, cpu in cores and others in bytes", []string{"namespace", "limitrange", "type", "resource", "constraint"}, nil)
+)
+
+func (c K8sQuotaCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	namespace := c.Namespace
+	if namespace == "" {
+		namespace = monitor_info.Params_maps["namespace"]
+	}
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_quota_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	quotas, err := clustercache.ResourceQuotas(namespace)
+	if err != nil {
+		log.Printf("get resourcequotas error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_quota_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	limitranges, err := clustercache.LimitRanges(namespace)
+	if err != nil {
+		log.Printf("get limitranges error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_quota_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	for _, quota := range quotas {
+		for name, hard := range quota.Status.Hard {
+			labelValues := []string{quota.Namespace, quota.Name, string(name)}
+			hardValue := quotaValue(name, hard)
+			ch <- prometheus.MustNewConstMetric(k8s_resourcequota_hard, prometheus.GaugeValue, hardValue, labelValues...)
+			used, ok := quota.Status.Used[name]
+			if !ok {
+				continue
+			}
+			usedValue := quotaValue(name, used)
+			ch <- prometheus.MustNewConstMetric(k8s_resourcequota_used, prometheus.GaugeValue, usedValue, labelValues...)
+			if hardValue > 0 {
+				ch <- prometheus.MustNewConstMetric(k8s_resourcequota_used_ratio, prometheus.GaugeValue, usedValue/hardValue, labelValues...)
+			}
+		}
+	}
+	for _, lr := range limitranges {
+		for _, item := range lr.Spec.Limits {
+			constraints := []struct {
+				name      string
+				resources apiv1.ResourceList
+			}{
+				{"min", item.Min}, {"max", item.Max}, {"default", item.Default},
+				{"defaultRequest", item.DefaultRequest}, {"maxLimitRequestRatio", item.MaxLimitRequestRatio},
+			}
+			for _, constraint := range constraints {
+				for name, q := range constraint.resources {
+					value := quantityValue(name, q)
+					if constraint.name == "maxLimitRequestRatio" {
+						value = float64(q.MilliValue()) / 1000
+					}
+					ch <- prometheus.MustNewConstMetric(k8s_limitrange, prometheus.GaugeValue, value,
+						lr.Namespace, lr.Name, string(item.Type), string(name), constraint.name)
+				}
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_quota_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+// quotaValue is quantityValue for quota resource names, which qualify cpu
+// with requests. or limits.
+func quotaValue(name apiv1.ResourceName, q resource.Quantity) float64 {
+	if name == apiv1.ResourceRequestsCPU || name == apiv1.ResourceLimitsCPU {
+		return quantityValue(apiv1.ResourceCPU, q)
+	}
+	return quantityValue(name, q)
+}
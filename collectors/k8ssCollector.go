@@ -0,0 +1,260 @@
+package collectors
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/labels"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// K8sServiceCollector exports the Services of a cluster with the state of
+// their Endpoints. Namespace and Selector narrow them down and default to the
+// namespace and label_selector params of the target. When Probe is "true",
+// or empty and the service_probe param is true, every ready endpoint gets a
+// TCP connect check; "false" turns the checks off whatever the target says.
+type K8sServiceCollector struct {
+	Target    string
+	Namespace string
+	Selector  string
+	Probe     string
+}
+
+func (c K8sServiceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+// ServiceProbeTimeout bounds each TCP connect check of /k8ss.
+var ServiceProbeTimeout = time.Second
+
+// ServiceProbeBudget bounds all the TCP connect checks of a scrape together,
+// checks that have not started by then are skipped.
+var ServiceProbeBudget = 5 * time.Second
+
+// maxServiceProbes is how many TCP connect checks run at once.
+const maxServiceProbes = 32
+
+var (
+	service_label                 = []string{"namespace", "service"}
+	k8s_service_monitorstatus     = prometheus.NewDesc("k8s_service_monitorstatus", "k8s service monitor status", nil, nil)
+	k8s_service_info              = prometheus.NewDesc("k8s_service_info", "service type and cluster IP", append(service_label, "type", "cluster_ip"), nil)
+	k8s_service_port              = prometheus.NewDesc("k8s_service_port", "port exposed by the service", append(service_label, "port_name", "protocol", "port", "target_port", "node_port"), nil)
+	k8s_service_endpoints_ready   = prometheus.NewDesc("k8s_service_endpoints_ready", "ready endpoint addresses of the service", service_label, nil)
+	k8s_service_endpoints_unready = prometheus.NewDesc("k8s_service_endpoints_not_ready", "not ready endpoint addresses of the service", service_label, nil)
+	k8s_service_selector_pods     = prometheus.NewDesc("k8s_service_selector_pods", "running or pending pods matched by the service selector", service_label, nil)
+	k8s_service_orphaned          = prometheus.NewDesc("k8s_service_orphaned", "whether the service selector matches no pod", service_label, nil)
+
+	endpoint_probe_label                = append(service_label, "port_name", "address", "port", "pod_name")
+	k8s_service_endpoint_probe_success  = prometheus.NewDesc("k8s_service_endpoint_probe_success", "whether a TCP connection to the ready endpoint succeeded", endpoint_probe_label, nil)
+	k8s_service_endpoint_probe_duration = prometheus.NewDesc("k8s_service_endpoint_probe_duration_seconds", "seconds the TCP connect check of the ready endpoint took", endpoint_probe_label, nil)
+	k8s_service_endpoint_probes_skipped = prometheus.NewDesc("k8s_service_endpoint_probes_skipped", "TCP connect checks skipped because the probe budget of the scrape ran out", nil, nil)
+)
+
+// endpointProbe is the TCP connect check of one ready endpoint address and port.
+type endpointProbe struct {
+	labelValues []string
+	address     string
+	success     bool
+	skipped     bool
+	duration    time.Duration
+}
+
+func (c K8sServiceCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	namespace := c.Namespace
+	if namespace == "" {
+		namespace = monitor_info.Params_maps["namespace"]
+	}
+	selectorString := c.Selector
+	if selectorString == "" {
+		selectorString = monitor_info.Params_maps["label_selector"]
+	}
+	selector, err := labels.Parse(selectorString)
+	if err != nil {
+		log.Printf("parse label selector %q error: %s", selectorString, err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_service_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	probeString := c.Probe
+	if probeString == "" {
+		probeString = monitor_info.Params_maps["service_probe"]
+	}
+	probe := false
+	if probeString != "" {
+		if probe, err = strconv.ParseBool(probeString); err != nil {
+			log.Printf("parse probe %q error: %s", probeString, err.Error())
+			ch <- prometheus.MustNewConstMetric(k8s_service_monitorstatus, prometheus.GaugeValue, float64(0))
+			return
+		}
+	}
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_service_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	services, err := clustercache.Services(namespace)
+	if err != nil {
+		log.Printf("get services list error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_service_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	endpoints, err := clustercache.Endpoints(namespace)
+	if err != nil {
+		log.Printf("get endpoints list error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_service_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	var pods []*apiv1.Pod
+	if namespace == "" {
+		pods = clustercache.Pods()
+	} else if pods, err = clustercache.PodsInNamespace(namespace); err != nil {
+		log.Printf("get pods list error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_service_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	endpointsByService := make(map[string]*apiv1.Endpoints, len(endpoints))
+	for _, ep := range endpoints {
+		endpointsByService[ep.Namespace+"/"+ep.Name] = ep
+	}
+
+	var probes []*endpointProbe
+	for _, service := range services {
+		if !selector.Matches(labels.Set(service.Labels)) {
+			continue
+		}
+		labelValues := []string{service.Namespace, service.Name}
+		ch <- prometheus.MustNewConstMetric(k8s_service_info, prometheus.GaugeValue, 1,
+			append(labelValues, string(service.Spec.Type), service.Spec.ClusterIP)...)
+		for _, port := range service.Spec.Ports {
+			ch <- prometheus.MustNewConstMetric(k8s_service_port, prometheus.GaugeValue, 1,
+				append(labelValues, port.Name, string(port.Protocol), strconv.Itoa(int(port.Port)),
+					port.TargetPort.String(), strconv.Itoa(int(port.NodePort)))...)
+		}
+		// Services without a selector have their Endpoints managed by hand.
+		if len(service.Spec.Selector) > 0 {
+			matched := countSelectedPods(pods, service)
+			orphaned := 0.0
+			if matched == 0 {
+				orphaned = 1
+			}
+			ch <- prometheus.MustNewConstMetric(k8s_service_selector_pods, prometheus.GaugeValue, float64(matched), labelValues...)
+			ch <- prometheus.MustNewConstMetric(k8s_service_orphaned, prometheus.GaugeValue, orphaned, labelValues...)
+		}
+		var ready, notReady int
+		if ep, ok := endpointsByService[service.Namespace+"/"+service.Name]; ok {
+			for _, subset := range ep.Subsets {
+				ready += len(subset.Addresses)
+				notReady += len(subset.NotReadyAddresses)
+				if probe {
+					probes = append(probes, subsetProbes(labelValues, subset)...)
+				}
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_service_endpoints_ready, prometheus.GaugeValue, float64(ready), labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_service_endpoints_unready, prometheus.GaugeValue, float64(notReady), labelValues...)
+	}
+	skipped := runEndpointProbes(probes, time.Now().Add(ServiceProbeBudget))
+	for _, p := range probes {
+		if p.skipped {
+			continue
+		}
+		success := 0.0
+		if p.success {
+			success = 1
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_service_endpoint_probe_success, prometheus.GaugeValue, success, p.labelValues...)
+		ch <- prometheus.MustNewConstMetric(k8s_service_endpoint_probe_duration, prometheus.GaugeValue, p.duration.Seconds(), p.labelValues...)
+	}
+	if probe {
+		if skipped > 0 {
+			log.Printf("probe budget of %s ran out, skipped %d of %d endpoint probes", ServiceProbeBudget, skipped, len(probes))
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_service_endpoint_probes_skipped, prometheus.GaugeValue, float64(skipped))
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_service_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+// countSelectedPods counts the pods of the service namespace its selector
+// matches, leaving out pods that have terminated.
+func countSelectedPods(pods []*apiv1.Pod, service *apiv1.Service) int {
+	selector := labels.SelectorFromSet(service.Spec.Selector)
+	count := 0
+	for _, pod := range pods {
+		if pod.Namespace != service.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if pod.Status.Phase == apiv1.PodSucceeded || pod.Status.Phase == apiv1.PodFailed {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// subsetProbes lists the TCP ports of the ready addresses of an endpoint subset.
+func subsetProbes(serviceLabelValues []string, subset apiv1.EndpointSubset) []*endpointProbe {
+	var probes []*endpointProbe
+	for _, address := range subset.Addresses {
+		podName := ""
+		if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+			podName = address.TargetRef.Name
+		}
+		for _, port := range subset.Ports {
+			if port.Protocol != apiv1.ProtocolTCP {
+				continue
+			}
+			portNumber := strconv.Itoa(int(port.Port))
+			probes = append(probes, &endpointProbe{
+				labelValues: append(append([]string{}, serviceLabelValues...), port.Name, address.IP, portNumber, podName),
+				address:     net.JoinHostPort(address.IP, portNumber),
+			})
+		}
+	}
+	return probes
+}
+
+// runEndpointProbes runs the probes until deadline, shortening the last ones
+// to fit, and returns how many it skipped.
+func runEndpointProbes(probes []*endpointProbe, deadline time.Time) int {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxServiceProbes)
+	skipped := 0
+	for _, p := range probes {
+		sem <- struct{}{}
+		timeout := deadline.Sub(time.Now())
+		if timeout <= 0 {
+			<-sem
+			p.skipped = true
+			skipped++
+			continue
+		}
+		if timeout > ServiceProbeTimeout {
+			timeout = ServiceProbeTimeout
+		}
+		wg.Add(1)
+		go func(p *endpointProbe, timeout time.Duration) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start := time.Now()
+			conn, err := net.DialTimeout("tcp", p.address, timeout)
+			p.duration = time.Since(start)
+			if err != nil {
+				log.Printf("probe endpoint %s error: %s", p.address, err.Error())
+				return
+			}
+			conn.Close()
+			p.success = true
+		}(p, timeout)
+	}
+	wg.Wait()
+	return skipped
+}
@@ -0,0 +1,42 @@
+package collectors
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRunEndpointProbes(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	probes := []*endpointProbe{{address: l.Addr().String()}, {address: closedAddress}}
+	if skipped := runEndpointProbes(probes, time.Now().Add(time.Minute)); skipped != 0 {
+		t.Errorf("skipped %d probes within the budget", skipped)
+	}
+	if !probes[0].success || probes[0].skipped {
+		t.Errorf("probe of a listening port: %+v", probes[0])
+	}
+	if probes[1].success || probes[1].skipped {
+		t.Errorf("probe of a closed port: %+v", probes[1])
+	}
+
+	probes = []*endpointProbe{{address: l.Addr().String()}, {address: l.Addr().String()}}
+	if skipped := runEndpointProbes(probes, time.Now()); skipped != 2 {
+		t.Errorf("skipped %d probes past the deadline, want 2", skipped)
+	}
+	for _, p := range probes {
+		if p.success || !p.skipped {
+			t.Errorf("probe past the deadline: %+v", p)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package collectors
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/collectors/kubelet"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// K8sVolumeCollector exports the PersistentVolumes, PersistentVolumeClaims
+// and StorageClasses of a cluster. Claims are limited to the namespace param
+// of the target when it is set.
+type K8sVolumeCollector struct {
+	Target string
+}
+
+func (c K8sVolumeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+var (
+	pv_label                 = []string{"persistentvolume"}
+	pvc_label                = []string{"namespace", "persistentvolumeclaim"}
+	k8s_volume_monitorstatus = prometheus.NewDesc("k8s_volume_monitorstatus", "k8s volume monitor status", nil, nil)
+	k8s_storageclass_info    = prometheus.NewDesc("k8s_storageclass_info", "storage class information", []string{"storageclass", "provisioner", "default"}, nil)
+	k8s_pv_phase             = prometheus.NewDesc("k8s_pv_phase", "persistent volume phase, 1 for the current phase", append(pv_label, "phase"), nil)
+	k8s_pv_capacity_bytes    = prometheus.NewDesc("k8s_pv_capacity_bytes", "persistent volume capacity in bytes", pv_label, nil)
+	k8s_pv_info              = prometheus.NewDesc("k8s_pv_info", "persistent volume information",
+		append(pv_label, "storageclass", "access_modes", "reclaim_policy", "claim_namespace", "claim"), nil)
+	k8s_pvc_phase           = prometheus.NewDesc("k8s_pvc_phase", "persistent volume claim phase, 1 for the current phase", append(pvc_label, "phase"), nil)
+	k8s_pvc_requested_bytes = prometheus.NewDesc("k8s_pvc_requested_bytes", "storage requested by the claim in bytes", pvc_label, nil)
+	k8s_pvc_capacity_bytes  = prometheus.NewDesc("k8s_pvc_capacity_bytes", "capacity of the volume bound to the claim in bytes", pvc_label, nil)
+	k8s_pvc_info            = prometheus.NewDesc("k8s_pvc_info", "persistent volume claim information",
+		append(pvc_label, "storageclass", "access_modes", "volume"), nil)
+	k8s_pvc_used_bytes        = prometheus.NewDesc("k8s_pvc_used_bytes", "bytes used on the volume of the claim as seen by the kubelet", pvc_label, nil)
+	k8s_pvc_available_bytes   = prometheus.NewDesc("k8s_pvc_available_bytes", "bytes available on the volume of the claim as seen by the kubelet", pvc_label, nil)
+	k8s_pvc_fs_capacity_bytes = prometheus.NewDesc("k8s_pvc_fs_capacity_bytes", "filesystem capacity of the volume of the claim as seen by the kubelet", pvc_label, nil)
+	k8s_pvc_inodes            = prometheus.NewDesc("k8s_pvc_inodes", "inodes of the volume of the claim", pvc_label, nil)
+	k8s_pvc_inodes_free       = prometheus.NewDesc("k8s_pvc_inodes_free", "free inodes of the volume of the claim", pvc_label, nil)
+	k8s_pvc_inodes_used       = prometheus.NewDesc("k8s_pvc_inodes_used", "used inodes of the volume of the claim", pvc_label, nil)
+
+	k8s_volume_storageclass_monitorstatus = prometheus.NewDesc("k8s_volume_storageclass_monitorstatus", "whether the storage classes of the cluster could be read", nil, nil)
+	k8s_volume_node_summary_success       = prometheus.NewDesc("k8s_volume_node_summary_success", "whether the kubelet summary of a node mounting claims could be fetched", []string{"node"}, nil)
+)
+
+var (
+	pvPhases  = []apiv1.PersistentVolumePhase{apiv1.VolumePending, apiv1.VolumeAvailable, apiv1.VolumeBound, apiv1.VolumeReleased, apiv1.VolumeFailed}
+	pvcPhases = []apiv1.PersistentVolumeClaimPhase{apiv1.ClaimPending, apiv1.ClaimBound, apiv1.ClaimLost}
+)
+
+const (
+	betaStorageClassAnnotation = "volume.beta.kubernetes.io/storage-class"
+	defaultClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+func (c K8sVolumeCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	namespace := monitor_info.Params_maps["namespace"]
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_volume_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	pvs, err := clustercache.PersistentVolumes()
+	if err != nil {
+		log.Printf("get persistent volumes error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_volume_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	pvcs, err := clustercache.PersistentVolumeClaims(namespace)
+	if err != nil {
+		log.Printf("get persistent volume claims error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_volume_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	// Storage classes only describe the volumes, the volumes are exported
+	// without them.
+	classes, err := clustercache.StorageClasses()
+	if err != nil {
+		log.Printf("get storage classes error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_volume_storageclass_monitorstatus, prometheus.GaugeValue, float64(0))
+	} else {
+		for _, sc := range classes {
+			isDefault := "false"
+			if sc.Annotations[defaultClassAnnotation] == "true" || sc.Annotations[betaDefaultClassAnnotation] == "true" {
+				isDefault = "true"
+			}
+			ch <- prometheus.MustNewConstMetric(k8s_storageclass_info, prometheus.GaugeValue, 1, sc.Name, sc.Provisioner, isDefault)
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_volume_storageclass_monitorstatus, prometheus.GaugeValue, float64(1))
+	}
+	for _, pv := range pvs {
+		for _, phase := range pvPhases {
+			value := 0.0
+			if pv.Status.Phase == phase {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(k8s_pv_phase, prometheus.GaugeValue, value, pv.Name, string(phase))
+		}
+		if q, ok := pv.Spec.Capacity[apiv1.ResourceStorage]; ok {
+			ch <- prometheus.MustNewConstMetric(k8s_pv_capacity_bytes, prometheus.GaugeValue, float64(q.Value()), pv.Name)
+		}
+		claimNamespace, claim := "", ""
+		if pv.Spec.ClaimRef != nil {
+			claimNamespace, claim = pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name
+		}
+		storageClass := pv.Spec.StorageClassName
+		if storageClass == "" {
+			storageClass = pv.Annotations[betaStorageClassAnnotation]
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_pv_info, prometheus.GaugeValue, 1, pv.Name, storageClass,
+			accessModesString(pv.Spec.AccessModes), string(pv.Spec.PersistentVolumeReclaimPolicy), claimNamespace, claim)
+	}
+	for _, pvc := range pvcs {
+		for _, phase := range pvcPhases {
+			value := 0.0
+			if pvc.Status.Phase == phase {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(k8s_pvc_phase, prometheus.GaugeValue, value, pvc.Namespace, pvc.Name, string(phase))
+		}
+		if q, ok := pvc.Spec.Resources.Requests[apiv1.ResourceStorage]; ok {
+			ch <- prometheus.MustNewConstMetric(k8s_pvc_requested_bytes, prometheus.GaugeValue, float64(q.Value()), pvc.Namespace, pvc.Name)
+		}
+		if q, ok := pvc.Status.Capacity[apiv1.ResourceStorage]; ok {
+			ch <- prometheus.MustNewConstMetric(k8s_pvc_capacity_bytes, prometheus.GaugeValue, float64(q.Value()), pvc.Namespace, pvc.Name)
+		}
+		storageClass := pvc.Annotations[betaStorageClassAnnotation]
+		if pvc.Spec.StorageClassName != nil {
+			storageClass = *pvc.Spec.StorageClassName
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_pvc_info, prometheus.GaugeValue, 1, pvc.Namespace, pvc.Name, storageClass,
+			accessModesString(pvc.Status.AccessModes), pvc.Spec.VolumeName)
+	}
+	collectVolumeStats(ch, clustercache, monitor_info.Params_maps, namespace)
+	ch <- prometheus.MustNewConstMetric(k8s_volume_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+// VolumeSummaryTimeout bounds the summary fetch of each node in /k8sv.
+var VolumeSummaryTimeout = 10 * time.Second
+
+// maxVolumeSummaryFetches is how many node summaries /k8sv fetches at once.
+const maxVolumeSummaryFetches = 16
+
+// nodeSummary is the summary fetch of one node for the volume stats.
+type nodeSummary struct {
+	name    string
+	summary *kubelet.Summary
+}
+
+// collectVolumeStats exports the usage of claimed volumes from the summary of
+// every node running a pod that mounts a claim. A claim mounted by several
+// pods is reported once.
+func collectVolumeStats(ch chan<- prometheus.Metric, clustercache *kube.ClusterCache, params map[string]string, namespace string) {
+	source := kubelet.Source(params)
+	if source == kubelet.SourceCadvisor {
+		return
+	}
+	nodes := map[string]bool{}
+	for _, pod := range clustercache.Pods() {
+		if pod.Spec.NodeName == "" || (namespace != "" && pod.Namespace != namespace) {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				nodes[pod.Spec.NodeName] = true
+				break
+			}
+		}
+	}
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	summaries := make([]*nodeSummary, 0, len(names))
+	for _, name := range names {
+		summaries = append(summaries, &nodeSummary{name: name})
+	}
+	fetchNodeSummaries(clustercache, params, source, summaries)
+
+	seen := map[kubelet.PVCReference]bool{}
+	for _, ns := range summaries {
+		success := 0.0
+		if ns.summary != nil {
+			success = 1
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_volume_node_summary_success, prometheus.GaugeValue, success, ns.name)
+		if ns.summary == nil {
+			continue
+		}
+		for _, pod := range ns.summary.Pods {
+			for _, volume := range pod.VolumeStats {
+				if volume.PVCRef == nil || seen[*volume.PVCRef] || (namespace != "" && volume.PVCRef.Namespace != namespace) {
+					continue
+				}
+				seen[*volume.PVCRef] = true
+				labelValues := []string{volume.PVCRef.Namespace, volume.PVCRef.Name}
+				ch <- prometheus.MustNewConstMetric(k8s_pvc_used_bytes, prometheus.GaugeValue, float64(kubelet.Value(volume.UsedBytes)), labelValues...)
+				ch <- prometheus.MustNewConstMetric(k8s_pvc_available_bytes, prometheus.GaugeValue, float64(kubelet.Value(volume.AvailableBytes)), labelValues...)
+				ch <- prometheus.MustNewConstMetric(k8s_pvc_fs_capacity_bytes, prometheus.GaugeValue, float64(kubelet.Value(volume.CapacityBytes)), labelValues...)
+				if volume.Inodes != nil {
+					ch <- prometheus.MustNewConstMetric(k8s_pvc_inodes, prometheus.GaugeValue, float64(kubelet.Value(volume.Inodes)), labelValues...)
+					ch <- prometheus.MustNewConstMetric(k8s_pvc_inodes_free, prometheus.GaugeValue, float64(kubelet.Value(volume.InodesFree)), labelValues...)
+					ch <- prometheus.MustNewConstMetric(k8s_pvc_inodes_used, prometheus.GaugeValue, float64(kubelet.Value(volume.InodesUsed)), labelValues...)
+				}
+			}
+		}
+	}
+}
+
+// fetchNodeSummaries fills in the summaries of the nodes, a few at a time and
+// each within VolumeSummaryTimeout. Nodes that fail keep a nil summary.
+func fetchNodeSummaries(clustercache *kube.ClusterCache, params map[string]string, source string, summaries []*nodeSummary) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxVolumeSummaryFetches)
+	for _, ns := range summaries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ns *nodeSummary) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			node, err := clustercache.Node(ns.name)
+			if err != nil {
+				log.Printf("get node error: %s", err.Error())
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), VolumeSummaryTimeout)
+			defer cancel()
+			summary, err := kubelet.GetSummaryContext(ctx, params, source, nodeInternalIP(node), node.Name)
+			if err != nil {
+				log.Printf("get summary of node %s error: %s", node.Name, err.Error())
+				return
+			}
+			ns.summary = summary
+		}(ns)
+	}
+	wg.Wait()
+}
+
+// accessModesString joins access modes in a stable order, e.g.
+// "ReadOnlyMany,ReadWriteOnce".
+func accessModesString(modes []apiv1.PersistentVolumeAccessMode) string {
+	names := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		names = append(names, string(mode))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,269 @@
+package collectors
+
+import (
+	"log"
+	"time"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	extv1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// K8sWorkloadCollector exports the Deployments, StatefulSets, DaemonSets and
+// ReplicaSets of a cluster. Namespace and Selector narrow them down and
+// default to the namespace and label_selector params of the target.
+type K8sWorkloadCollector struct {
+	Target    string
+	Namespace string
+	Selector  string
+}
+
+func (c K8sWorkloadCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+}
+
+var (
+	workload_label                    = []string{"namespace", "workload", "kind"}
+	k8s_workload_monitorstatus        = prometheus.NewDesc("k8s_workload_monitorstatus", "k8s workload monitor status", nil, nil)
+	k8s_workload_replicas_desired     = prometheus.NewDesc("k8s_workload_replicas_desired", "number of desired replicas", workload_label, nil)
+	k8s_workload_replicas_current     = prometheus.NewDesc("k8s_workload_replicas_current", "number of current replicas", workload_label, nil)
+	k8s_workload_replicas_ready       = prometheus.NewDesc("k8s_workload_replicas_ready", "number of ready replicas", workload_label, nil)
+	k8s_workload_replicas_available   = prometheus.NewDesc("k8s_workload_replicas_available", "number of available replicas", workload_label, nil)
+	k8s_workload_replicas_updated     = prometheus.NewDesc("k8s_workload_replicas_updated", "number of replicas running the latest template", workload_label, nil)
+	k8s_workload_replicas_unavailable = prometheus.NewDesc("k8s_workload_replicas_unavailable", "number of unavailable replicas", workload_label, nil)
+	k8s_workload_generation           = prometheus.NewDesc("k8s_workload_generation", "generation of the desired state", workload_label, nil)
+	k8s_workload_observed_generation  = prometheus.NewDesc("k8s_workload_observed_generation", "generation observed by the controller", workload_label, nil)
+	k8s_workload_rollout_stuck        = prometheus.NewDesc("k8s_workload_rollout_stuck", "whether the rollout exceeded its progress deadline", workload_label, nil)
+	k8s_workload_rollout_age_seconds  = prometheus.NewDesc("k8s_workload_rollout_age_seconds", "seconds since the current revision was rolled out", workload_label, nil)
+	k8s_workload_rollout_paused       = prometheus.NewDesc("k8s_workload_rollout_paused", "whether the rollout is paused", workload_label, nil)
+)
+
+const (
+	kindDeployment  = "Deployment"
+	kindStatefulSet = "StatefulSet"
+	kindDaemonSet   = "DaemonSet"
+	kindReplicaSet  = "ReplicaSet"
+
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+	// timedOutReason is the Progressing condition reason of a deployment
+	// that exceeded progressDeadlineSeconds.
+	timedOutReason = "ProgressDeadlineExceeded"
+)
+
+// workloadStatus is the part of the status shared by the workload kinds.
+// available and updated are nil for kinds whose status does not report them.
+type workloadStatus struct {
+	desired, current, ready, unavailable int32
+	available, updated                   *int32
+	generation, observedGeneration       int64
+}
+
+func (c K8sWorkloadCollector) Collect(ch chan<- prometheus.Metric) {
+	monitor_info := config.GetMonitorInfo(c.Target)
+	namespace := c.Namespace
+	if namespace == "" {
+		namespace = monitor_info.Params_maps["namespace"]
+	}
+	selectorString := c.Selector
+	if selectorString == "" {
+		selectorString = monitor_info.Params_maps["label_selector"]
+	}
+	selector, err := labels.Parse(selectorString)
+	if err != nil {
+		log.Printf("parse label selector %q error: %s", selectorString, err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_workload_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	clustercache, err := kube.Caches.Get(monitor_info.Params_maps)
+	if err != nil {
+		log.Printf("get cluster cache error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_workload_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	deployments, err := clustercache.Deployments(namespace)
+	if err != nil {
+		log.Printf("get deployments error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_workload_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	replicasets, err := clustercache.ReplicaSets(namespace)
+	if err != nil {
+		log.Printf("get replicasets error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_workload_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	daemonsets, err := clustercache.DaemonSets(namespace)
+	if err != nil {
+		log.Printf("get daemonsets error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_workload_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+	statefulsets, err := clustercache.StatefulSets(namespace)
+	if err != nil {
+		log.Printf("get statefulsets error: %s", err.Error())
+		ch <- prometheus.MustNewConstMetric(k8s_workload_monitorstatus, prometheus.GaugeValue, float64(0))
+		return
+	}
+
+	for _, d := range deployments {
+		if !selector.Matches(labels.Set(d.Labels)) {
+			continue
+		}
+		labelValues := []string{d.Namespace, d.Name, kindDeployment}
+		collectWorkloadStatus(ch, workloadStatus{
+			desired:            replicasOf(d.Spec.Replicas),
+			current:            d.Status.Replicas,
+			ready:              d.Status.ReadyReplicas,
+			available:          &d.Status.AvailableReplicas,
+			updated:            &d.Status.UpdatedReplicas,
+			unavailable:        d.Status.UnavailableReplicas,
+			generation:         d.Generation,
+			observedGeneration: d.Status.ObservedGeneration,
+		}, labelValues)
+		stuck := 0.0
+		for _, condition := range d.Status.Conditions {
+			if condition.Type == extv1beta1.DeploymentProgressing && condition.Status == apiv1.ConditionFalse &&
+				condition.Reason == timedOutReason {
+				stuck = 1
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_workload_rollout_stuck, prometheus.GaugeValue, stuck, labelValues...)
+		paused := 0.0
+		if d.Spec.Paused {
+			paused = 1
+		}
+		ch <- prometheus.MustNewConstMetric(k8s_workload_rollout_paused, prometheus.GaugeValue, paused, labelValues...)
+		if rs := currentReplicaSet(d, replicasets); rs != nil {
+			ch <- prometheus.MustNewConstMetric(k8s_workload_rollout_age_seconds, prometheus.GaugeValue,
+				time.Since(rs.CreationTimestamp.Time).Seconds(), labelValues...)
+		}
+	}
+	for _, rs := range replicasets {
+		if !selector.Matches(labels.Set(rs.Labels)) {
+			continue
+		}
+		collectWorkloadStatus(ch, workloadStatus{
+			desired:            replicasOf(rs.Spec.Replicas),
+			current:            rs.Status.Replicas,
+			ready:              rs.Status.ReadyReplicas,
+			available:          &rs.Status.AvailableReplicas,
+			unavailable:        replicasOf(rs.Spec.Replicas) - rs.Status.AvailableReplicas,
+			generation:         rs.Generation,
+			observedGeneration: rs.Status.ObservedGeneration,
+		}, []string{rs.Namespace, rs.Name, kindReplicaSet})
+	}
+	for _, ds := range daemonsets {
+		if !selector.Matches(labels.Set(ds.Labels)) {
+			continue
+		}
+		collectWorkloadStatus(ch, workloadStatus{
+			desired:            ds.Status.DesiredNumberScheduled,
+			current:            ds.Status.CurrentNumberScheduled,
+			ready:              ds.Status.NumberReady,
+			available:          &ds.Status.NumberAvailable,
+			updated:            &ds.Status.UpdatedNumberScheduled,
+			unavailable:        ds.Status.NumberUnavailable,
+			generation:         ds.Generation,
+			observedGeneration: ds.Status.ObservedGeneration,
+		}, []string{ds.Namespace, ds.Name, kindDaemonSet})
+	}
+	for _, sts := range statefulsets {
+		if !selector.Matches(labels.Set(sts.Labels)) {
+			continue
+		}
+		// The StatefulSet status of this API version only carries the
+		// replica count, readiness is taken from the pods it owns. It has
+		// no revisions either, so updated and available are left out.
+		pods, err := clustercache.PodsInNamespace(sts.Namespace)
+		if err != nil {
+			log.Printf("get pods of statefulset %s/%s error: %s", sts.Namespace, sts.Name, err.Error())
+			continue
+		}
+		ready := int32(0)
+		for _, pod := range pods {
+			if isControlledBy(pod.OwnerReferences, sts.UID) && podReady(pod) {
+				ready++
+			}
+		}
+		var observed int64
+		if sts.Status.ObservedGeneration != nil {
+			observed = *sts.Status.ObservedGeneration
+		}
+		desired := replicasOf(sts.Spec.Replicas)
+		collectWorkloadStatus(ch, workloadStatus{
+			desired:            desired,
+			current:            sts.Status.Replicas,
+			ready:              ready,
+			unavailable:        desired - ready,
+			generation:         sts.Generation,
+			observedGeneration: observed,
+		}, []string{sts.Namespace, sts.Name, kindStatefulSet})
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_workload_monitorstatus, prometheus.GaugeValue, float64(1))
+}
+
+func collectWorkloadStatus(ch chan<- prometheus.Metric, s workloadStatus, labelValues []string) {
+	if s.unavailable < 0 {
+		s.unavailable = 0
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_workload_replicas_desired, prometheus.GaugeValue, float64(s.desired), labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_workload_replicas_current, prometheus.GaugeValue, float64(s.current), labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_workload_replicas_ready, prometheus.GaugeValue, float64(s.ready), labelValues...)
+	if s.available != nil {
+		ch <- prometheus.MustNewConstMetric(k8s_workload_replicas_available, prometheus.GaugeValue, float64(*s.available), labelValues...)
+	}
+	if s.updated != nil {
+		ch <- prometheus.MustNewConstMetric(k8s_workload_replicas_updated, prometheus.GaugeValue, float64(*s.updated), labelValues...)
+	}
+	ch <- prometheus.MustNewConstMetric(k8s_workload_replicas_unavailable, prometheus.GaugeValue, float64(s.unavailable), labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_workload_generation, prometheus.GaugeValue, float64(s.generation), labelValues...)
+	ch <- prometheus.MustNewConstMetric(k8s_workload_observed_generation, prometheus.GaugeValue, float64(s.observedGeneration), labelValues...)
+}
+
+// replicasOf reads an optional replica count, which defaults to 1.
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// currentReplicaSet returns the ReplicaSet of the current revision of a
+// deployment, or nil. After a rollback the current one is an older
+// ReplicaSet, so it is matched on the revision annotation rather than age.
+func currentReplicaSet(d *extv1beta1.Deployment, replicasets []*extv1beta1.ReplicaSet) *extv1beta1.ReplicaSet {
+	revision := d.Annotations[revisionAnnotation]
+	if revision == "" {
+		return nil
+	}
+	for _, rs := range replicasets {
+		if rs.Namespace == d.Namespace && isControlledBy(rs.OwnerReferences, d.UID) &&
+			rs.Annotations[revisionAnnotation] == revision {
+			return rs
+		}
+	}
+	return nil
+}
+
+func isControlledBy(refs []metav1.OwnerReference, uid types.UID) bool {
+	for _, ref := range refs {
+		if ref.Controller != nil && *ref.Controller && ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func podReady(pod *apiv1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == apiv1.PodReady {
+			return condition.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
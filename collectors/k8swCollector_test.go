@@ -0,0 +1,43 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	extv1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func TestCurrentReplicaSet(t *testing.T) {
+	controller := true
+	d := &extv1beta1.Deployment{}
+	d.Namespace, d.Name, d.UID = "default", "web", types.UID("d1")
+	replicaSet := func(name, revision string, created time.Time, owner types.UID) *extv1beta1.ReplicaSet {
+		rs := &extv1beta1.ReplicaSet{}
+		rs.Namespace, rs.Name = "default", name
+		rs.Annotations = map[string]string{revisionAnnotation: revision}
+		rs.CreationTimestamp = metav1.NewTime(created)
+		rs.OwnerReferences = []metav1.OwnerReference{{UID: owner, Controller: &controller}}
+		return rs
+	}
+	base := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	// web-a was rolled back to: it is revision 3 now, web-b is the newest.
+	replicasets := []*extv1beta1.ReplicaSet{
+		replicaSet("web-a", "3", base, "d1"),
+		replicaSet("web-b", "2", base.Add(time.Hour), "d1"),
+		replicaSet("other", "3", base.Add(2*time.Hour), "d2"),
+	}
+
+	if rs := currentReplicaSet(d, replicasets); rs != nil {
+		t.Errorf("currentReplicaSet without a revision = %s, want nil", rs.Name)
+	}
+	d.Annotations = map[string]string{revisionAnnotation: "3"}
+	if rs := currentReplicaSet(d, replicasets); rs == nil || rs.Name != "web-a" {
+		t.Errorf("currentReplicaSet = %v, want web-a", rs)
+	}
+	d.Annotations[revisionAnnotation] = "4"
+	if rs := currentReplicaSet(d, replicasets); rs != nil {
+		t.Errorf("currentReplicaSet of an unknown revision = %s, want nil", rs.Name)
+	}
+}
@@ -0,0 +1,29 @@
+package kube
+
+import (
+	autoscalingv1 "k8s.io/client-go/pkg/apis/autoscaling/v1"
+)
+
+// HorizontalPodAutoscalers come from autoscaling/v1 and are watched once a
+// scrape asks for them.
+
+func (c *ClusterCache) HorizontalPodAutoscalers(namespace string) ([]*autoscalingv1.HorizontalPodAutoscaler, error) {
+	return hpaItems(c.list(c.clientset.AutoscalingV1().RESTClient(), "horizontalpodautoscalers", &autoscalingv1.HorizontalPodAutoscaler{}, namespace))
+}
+
+// HorizontalPodAutoscalersIfSynced does not wait for the autoscalers to be
+// listed, for scrapes that only join them to other objects.
+func (c *ClusterCache) HorizontalPodAutoscalersIfSynced(namespace string) ([]*autoscalingv1.HorizontalPodAutoscaler, error) {
+	return hpaItems(c.listIfSynced(c.clientset.AutoscalingV1().RESTClient(), "horizontalpodautoscalers", &autoscalingv1.HorizontalPodAutoscaler{}, namespace))
+}
+
+func hpaItems(objs []interface{}, err error) ([]*autoscalingv1.HorizontalPodAutoscaler, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*autoscalingv1.HorizontalPodAutoscaler, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*autoscalingv1.HorizontalPodAutoscaler))
+	}
+	return items, nil
+}
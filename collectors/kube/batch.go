@@ -0,0 +1,61 @@
+package kube
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+	batchv2alpha1 "k8s.io/client-go/pkg/apis/batch/v2alpha1"
+)
+
+// Jobs come from batch/v1 and CronJobs from batch/v2alpha1, both watched once
+// a scrape asks for them. batch/v2alpha1 is off by default on the API server,
+// so CronJobs fail fast when it is not served instead of waiting for a sync
+// that never happens.
+
+func (c *ClusterCache) Jobs(namespace string) ([]*batchv1.Job, error) {
+	objs, err := c.list(c.clientset.BatchV1().RESTClient(), "jobs", &batchv1.Job{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*batchv1.Job, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*batchv1.Job))
+	}
+	return items, nil
+}
+
+func (c *ClusterCache) CronJobs(namespace string) ([]*batchv2alpha1.CronJob, error) {
+	if err := c.checkServed(batchv2alpha1.SchemeGroupVersion.String(), "cronjobs"); err != nil {
+		return nil, err
+	}
+	objs, err := c.list(c.clientset.BatchV2alpha1().RESTClient(), "cronjobs", &batchv2alpha1.CronJob{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*batchv2alpha1.CronJob, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*batchv2alpha1.CronJob))
+	}
+	return items, nil
+}
+
+// checkServed asks the API server whether it serves resource in groupVersion,
+// unless its informer is already running.
+func (c *ClusterCache) checkServed(groupVersion, resource string) error {
+	c.mu.Lock()
+	_, started := c.informers[resource]
+	c.mu.Unlock()
+	if started {
+		return nil
+	}
+	list, err := c.clientset.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return fmt.Errorf("discover %s on %s: %s", groupVersion, c.host, err.Error())
+	}
+	for _, r := range list.APIResources {
+		if r.Name == resource {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s/%s is not served by %s", groupVersion, resource, c.host)
+}
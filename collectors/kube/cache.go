@@ -0,0 +1,446 @@
+package kube
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	resourceNodes = "nodes"
+	resourcePods  = "pods"
+
+	podNodeIndex = "node"
+)
+
+// Caches is the cache manager shared by all collectors.
+var Caches = NewCacheManager(10*time.Minute, 30*time.Second)
+
+// CacheManager keeps one ClusterCache per API server. A cache is started on
+// the first scrape that needs it and stopped once no scrape used it for
+// idleTimeout.
+type CacheManager struct {
+	idleTimeout time.Duration
+	syncTimeout time.Duration
+
+	mu     sync.Mutex
+	caches map[string]*ClusterCache
+	done   chan struct{}
+}
+
+func NewCacheManager(idleTimeout, syncTimeout time.Duration) *CacheManager {
+	m := &CacheManager{
+		idleTimeout: idleTimeout,
+		syncTimeout: syncTimeout,
+		caches:      make(map[string]*ClusterCache),
+		done:        make(chan struct{}),
+	}
+	go m.expire()
+	return m
+}
+
+// SetTimeouts changes how long caches may stay idle and how long a scrape
+// waits for a new cache to sync. It is meant to be called at startup.
+func (m *CacheManager) SetTimeouts(idleTimeout, syncTimeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idleTimeout = idleTimeout
+	m.syncTimeout = syncTimeout
+}
+
+// Get returns the synced cache of the cluster described by params, starting
+// it if needed.
+func (m *CacheManager) Get(params map[string]string) (*ClusterCache, error) {
+	key := cacheKey(params)
+	m.mu.Lock()
+	c, ok := m.caches[key]
+	if !ok {
+		config, err := NewConfig(params)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+		// Watches are long running requests, they must not be cut by the
+		// client timeout used for scrapes.
+		config.Timeout = 0
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+		c = newClusterCache(config.Host, clientset, m.syncTimeout)
+		m.caches[key] = c
+		log.Printf("started cache for %s", c.host)
+	}
+	c.touch()
+	syncTimeout := m.syncTimeout
+	m.mu.Unlock()
+	if err := c.waitForSync(syncTimeout); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (m *CacheManager) expire() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+		m.mu.Lock()
+		for key, c := range m.caches {
+			if time.Since(c.lastUsed()) > m.idleTimeout {
+				log.Printf("stopping idle cache for %s", c.host)
+				c.Stop()
+				delete(m.caches, key)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Stop tears down every cache.
+func (m *CacheManager) Stop() {
+	close(m.done)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, c := range m.caches {
+		c.Stop()
+		delete(m.caches, key)
+	}
+}
+
+// cacheKey identifies a cluster together with the identity used to reach it,
+// ignoring the params that only select an object inside it.
+func cacheKey(params map[string]string) string {
+	keys := []string{ParamMasterIP, ParamAPIPort, ParamAPIScheme, ParamKubeconfig, ParamKubeContext,
+		ParamInCluster, ParamBearerToken, ParamTokenFile, ParamClientCert, ParamClientKey, ParamCAFile,
+		ParamInsecure, ParamTLSServerName}
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v := params[k]; v != "" {
+			parts = append(parts, k+"="+v)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+var (
+	cacheLabels      = []string{"cluster", "resource"}
+	k8s_cache_synced = prometheus.NewDesc("k8s_cache_synced",
+		"Whether the informer cache of a resource has completed its initial list", cacheLabels, nil)
+	k8s_cache_age_seconds = prometheus.NewDesc("k8s_cache_age_seconds",
+		"Seconds since the informer cache of a resource was started", cacheLabels, nil)
+	k8s_cache_last_event_age_seconds = prometheus.NewDesc("k8s_cache_last_event_age_seconds",
+		"Seconds since the informer cache of a resource last received an update", cacheLabels, nil)
+	k8s_cache_objects = prometheus.NewDesc("k8s_cache_objects",
+		"Number of objects held by the informer cache of a resource", cacheLabels, nil)
+)
+
+func (m *CacheManager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- k8s_cache_synced
+	ch <- k8s_cache_age_seconds
+	ch <- k8s_cache_last_event_age_seconds
+	ch <- k8s_cache_objects
+}
+
+func (m *CacheManager) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	caches := make([]*ClusterCache, 0, len(m.caches))
+	for _, c := range m.caches {
+		caches = append(caches, c)
+	}
+	m.mu.Unlock()
+	for _, c := range caches {
+		c.mu.Lock()
+		for name, ri := range c.informers {
+			labels := []string{c.host, name}
+			synced := 0.0
+			if ri.informer.HasSynced() {
+				synced = 1
+			}
+			ch <- prometheus.MustNewConstMetric(k8s_cache_synced, prometheus.GaugeValue, synced, labels...)
+			ch <- prometheus.MustNewConstMetric(k8s_cache_age_seconds, prometheus.GaugeValue,
+				time.Since(ri.started).Seconds(), labels...)
+			ch <- prometheus.MustNewConstMetric(k8s_cache_last_event_age_seconds, prometheus.GaugeValue,
+				time.Since(ri.lastEvent()).Seconds(), labels...)
+			ch <- prometheus.MustNewConstMetric(k8s_cache_objects, prometheus.GaugeValue,
+				float64(len(ri.informer.GetStore().ListKeys())), labels...)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// ClusterCache holds the informers of one cluster.
+type ClusterCache struct {
+	host        string
+	clientset   *kubernetes.Clientset
+	syncTimeout time.Duration
+	events      *eventCounter
+	stop        chan struct{}
+	used        int64
+
+	mu        sync.Mutex
+	informers map[string]*resourceInformer
+	stopped   bool
+}
+
+type resourceInformer struct {
+	informer cache.SharedIndexInformer
+	started  time.Time
+	event    int64
+}
+
+func (ri *resourceInformer) lastEvent() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&ri.event))
+}
+
+func (ri *resourceInformer) OnAdd(obj interface{}) {
+	atomic.StoreInt64(&ri.event, time.Now().UnixNano())
+}
+
+func (ri *resourceInformer) OnUpdate(oldObj, newObj interface{}) {
+	atomic.StoreInt64(&ri.event, time.Now().UnixNano())
+}
+
+func (ri *resourceInformer) OnDelete(obj interface{}) {
+	atomic.StoreInt64(&ri.event, time.Now().UnixNano())
+}
+
+func newClusterCache(host string, clientset *kubernetes.Clientset, syncTimeout time.Duration) *ClusterCache {
+	c := &ClusterCache{
+		host:        host,
+		clientset:   clientset,
+		syncTimeout: syncTimeout,
+		events:      newEventCounter(),
+		stop:        make(chan struct{}),
+		informers:   make(map[string]*resourceInformer),
+	}
+	c.informer(resourceNodes, &v1.Node{}, cache.Indexers{})
+	c.informer(resourcePods, &v1.Pod{}, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		podNodeIndex: func(obj interface{}) ([]string, error) {
+			pod, ok := obj.(*v1.Pod)
+			if !ok || pod.Spec.NodeName == "" {
+				return nil, nil
+			}
+			return []string{pod.Spec.NodeName}, nil
+		},
+	})
+	return c
+}
+
+// informer returns the informer watching resource across all namespaces of
+// the core group, starting it on first use.
+func (c *ClusterCache) informer(resource string, objType runtime.Object, indexers cache.Indexers) cache.SharedIndexInformer {
+	return c.groupInformer(c.clientset.CoreV1().RESTClient(), resource, objType, indexers)
+}
+
+// groupInformer is informer for a resource served by client. Resources are
+// told apart by name only, so the same name must not be used in two groups.
+func (c *ClusterCache) groupInformer(client rest.Interface, resource string, objType runtime.Object, indexers cache.Indexers, handlers ...cache.ResourceEventHandler) cache.SharedIndexInformer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ri, ok := c.informers[resource]; ok {
+		return ri.informer
+	}
+	lw := cache.NewListWatchFromClient(client, resource, metav1.NamespaceAll, fields.Everything())
+	ri := &resourceInformer{
+		informer: cache.NewSharedIndexInformer(lw, objType, 0, indexers),
+		started:  time.Now(),
+	}
+	// A cache stopped while a scrape still holds it runs nothing new, the
+	// informer never syncs and waitForInformer reports the stop.
+	if c.stopped {
+		return ri.informer
+	}
+	ri.informer.AddEventHandler(ri)
+	for _, h := range handlers {
+		ri.informer.AddEventHandler(h)
+	}
+	c.informers[resource] = ri
+	go ri.informer.Run(c.stop)
+	return ri.informer
+}
+
+// list returns the objects of a resource started on demand, in namespace or
+// in all namespaces when it is empty. A scrape that starts the informer waits
+// for its initial list.
+func (c *ClusterCache) list(client rest.Interface, resource string, objType runtime.Object, namespace string) ([]interface{}, error) {
+	informer := c.namespacedInformer(client, resource, objType)
+	if err := c.waitForInformer(informer, resource); err != nil {
+		return nil, err
+	}
+	return listNamespace(informer, namespace)
+}
+
+// listIfSynced is list for data a scrape can do without, it fails at once
+// while the informer has not synced.
+func (c *ClusterCache) listIfSynced(client rest.Interface, resource string, objType runtime.Object, namespace string) ([]interface{}, error) {
+	informer := c.namespacedInformer(client, resource, objType)
+	if err := c.checkSynced(informer, resource); err != nil {
+		return nil, err
+	}
+	return listNamespace(informer, namespace)
+}
+
+func (c *ClusterCache) namespacedInformer(client rest.Interface, resource string, objType runtime.Object) cache.SharedIndexInformer {
+	return c.groupInformer(client, resource, objType, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+}
+
+func listNamespace(informer cache.SharedIndexInformer, namespace string) ([]interface{}, error) {
+	if namespace == "" {
+		return informer.GetStore().List(), nil
+	}
+	return informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+}
+
+// waitForInformer waits for the initial list of an informer started on
+// demand.
+func (c *ClusterCache) waitForInformer(informer cache.SharedIndexInformer, resource string) error {
+	if informer.HasSynced() {
+		return nil
+	}
+	c.mu.Lock()
+	stopped := c.stopped
+	c.mu.Unlock()
+	if stopped {
+		return fmt.Errorf("cache for %s was stopped while idle, the next scrape starts a new one", c.host)
+	}
+	stop := make(chan struct{})
+	timer := time.AfterFunc(c.syncTimeout, func() { close(stop) })
+	defer timer.Stop()
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+		return fmt.Errorf("cache of %s for %s not synced after %s", resource, c.host, c.syncTimeout)
+	}
+	return nil
+}
+
+// checkSynced is waitForInformer for data a scrape can do without: it never
+// waits. An informer it starts goes on syncing in the background, for the
+// scrapes that follow; k8s_cache_synced shows the ones that never do.
+func (c *ClusterCache) checkSynced(informer cache.SharedIndexInformer, resource string) error {
+	if informer.HasSynced() {
+		return nil
+	}
+	return fmt.Errorf("cache of %s for %s not synced yet", resource, c.host)
+}
+
+func (c *ClusterCache) waitForSync(timeout time.Duration) error {
+	c.mu.Lock()
+	synced := make([]cache.InformerSynced, 0, len(c.informers))
+	for _, ri := range c.informers {
+		synced = append(synced, ri.informer.HasSynced)
+	}
+	c.mu.Unlock()
+	stop := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(stop) })
+	defer timer.Stop()
+	if !cache.WaitForCacheSync(stop, synced...) {
+		return fmt.Errorf("cache for %s not synced after %s", c.host, timeout)
+	}
+	return nil
+}
+
+func (c *ClusterCache) touch() {
+	atomic.StoreInt64(&c.used, time.Now().UnixNano())
+}
+
+func (c *ClusterCache) lastUsed() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.used))
+}
+
+// Stop stops the informers of the cache. Scrapes still holding it keep
+// reading what was already synced, resources they ask for afterwards fail
+// until they Get a new cache.
+func (c *ClusterCache) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.stopped {
+		c.stopped = true
+		close(c.stop)
+	}
+}
+
+// Host is the API server address of the cluster.
+func (c *ClusterCache) Host() string {
+	return c.host
+}
+
+func (c *ClusterCache) Nodes() []*v1.Node {
+	objs := c.informer(resourceNodes, &v1.Node{}, nil).GetStore().List()
+	nodes := make([]*v1.Node, 0, len(objs))
+	for _, obj := range objs {
+		nodes = append(nodes, obj.(*v1.Node))
+	}
+	return nodes
+}
+
+func (c *ClusterCache) Node(name string) (*v1.Node, error) {
+	obj, ok, err := c.informer(resourceNodes, &v1.Node{}, nil).GetStore().GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", name)
+	}
+	return obj.(*v1.Node), nil
+}
+
+func (c *ClusterCache) Pods() []*v1.Pod {
+	return toPods(c.informer(resourcePods, &v1.Pod{}, nil).GetStore().List())
+}
+
+func (c *ClusterCache) PodsOnNode(nodeName string) ([]*v1.Pod, error) {
+	objs, err := c.informer(resourcePods, &v1.Pod{}, nil).GetIndexer().ByIndex(podNodeIndex, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return toPods(objs), nil
+}
+
+func (c *ClusterCache) PodsInNamespace(namespace string) ([]*v1.Pod, error) {
+	objs, err := c.informer(resourcePods, &v1.Pod{}, nil).GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return toPods(objs), nil
+}
+
+func (c *ClusterCache) Pod(namespace, name string) (*v1.Pod, error) {
+	obj, ok, err := c.informer(resourcePods, &v1.Pod{}, nil).GetStore().GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("pod %s/%s not found", namespace, name)
+	}
+	return obj.(*v1.Pod), nil
+}
+
+func toPods(objs []interface{}) []*v1.Pod {
+	pods := make([]*v1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		pods = append(pods, obj.(*v1.Pod))
+	}
+	return pods
+}
@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestStoppedClusterCache(t *testing.T) {
+	// Nothing listens there, the informers never sync.
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClusterCache("127.0.0.1:1", clientset, 100*time.Millisecond)
+	c.Stop()
+	c.Stop()
+
+	if _, err := c.Jobs(""); err == nil || !strings.Contains(err.Error(), "stopped") {
+		t.Errorf("Jobs on a stopped cache: %v, want a stopped error", err)
+	}
+	c.mu.Lock()
+	_, started := c.informers["jobs"]
+	c.mu.Unlock()
+	if started {
+		t.Errorf("a stopped cache started an informer")
+	}
+}
+
+func TestRecentWarningsDoesNotWait(t *testing.T) {
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClusterCache("127.0.0.1:1", clientset, time.Minute)
+	defer c.Stop()
+
+	start := time.Now()
+	if _, err := c.RecentWarnings("Pod", "default", "web-0", time.Hour); err == nil {
+		t.Errorf("RecentWarnings on an unsynced cache succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("RecentWarnings waited %s for events", elapsed)
+	}
+}
+
+func TestIfSyncedDoesNotWait(t *testing.T) {
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClusterCache("127.0.0.1:1", clientset, time.Minute)
+	defer c.Stop()
+
+	start := time.Now()
+	if _, err := c.HorizontalPodAutoscalersIfSynced("default"); err == nil {
+		t.Errorf("HorizontalPodAutoscalersIfSynced on an unsynced cache succeeded")
+	}
+	if _, err := c.ReplicaSetsIfSynced("default"); err == nil {
+		t.Errorf("ReplicaSetsIfSynced on an unsynced cache succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("IfSynced lists waited %s", elapsed)
+	}
+}
+
+func TestStorageClassesFailFast(t *testing.T) {
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClusterCache("127.0.0.1:1", clientset, time.Minute)
+	defer c.Stop()
+
+	start := time.Now()
+	if _, err := c.StorageClasses(); err == nil {
+		t.Errorf("StorageClasses of an unreachable cluster succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("StorageClasses took %s to fail", elapsed)
+	}
+}
+
+func TestStorageClassesForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/apis/storage.k8s.io/v1" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"storage.k8s.io/v1","resources":[{"name":"storageclasses","namespaced":false,"kind":"StorageClass"}]}`))
+			return
+		}
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClusterCache(server.URL, clientset, time.Minute)
+	defer c.Stop()
+
+	start := time.Now()
+	if _, err := c.StorageClasses(); err == nil {
+		t.Errorf("StorageClasses without list permission succeeded")
+	} else if !strings.Contains(err.Error(), "list storageclasses") {
+		t.Errorf("StorageClasses failed before listing: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("StorageClasses took %s to fail", elapsed)
+	}
+}
@@ -0,0 +1,314 @@
+package kube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Keys of ConnectInfoData.Params_maps that control how the API server is
+// reached. Without any of them the insecure http://master_ip:api_port is
+// used, as before.
+const (
+	ParamMasterIP      = "master_ip"
+	ParamAPIPort       = "api_port"
+	ParamAPIScheme     = "api_scheme"
+	ParamKubeconfig    = "kubeconfig"
+	ParamKubeContext   = "kube_context"
+	ParamInCluster     = "in_cluster"
+	ParamBearerToken   = "bearer_token"
+	ParamTokenFile     = "bearer_token_file"
+	ParamClientCert    = "client_cert"
+	ParamClientKey     = "client_key"
+	ParamCAFile        = "ca_file"
+	ParamInsecure      = "insecure_skip_tls_verify"
+	ParamTLSServerName = "tls_server_name"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+	clientTimeout     = 30 * time.Second
+)
+
+// NewConfig builds the rest.Config for the cluster described by params.
+func NewConfig(params map[string]string) (*rest.Config, error) {
+	config, err := AuthConfig(params)
+	if err != nil {
+		return nil, err
+	}
+	if config.Host == "" {
+		return nil, fmt.Errorf("no API server address: set master_ip and api_port, kubeconfig or in_cluster")
+	}
+	return config, nil
+}
+
+// AuthConfig is NewConfig without requiring an API server address, for
+// clients that talk to other cluster components with the same credentials.
+func AuthConfig(params map[string]string) (*rest.Config, error) {
+	var config *rest.Config
+	var err error
+	switch {
+	case params[ParamInCluster] == "true":
+		config, err = inClusterConfig()
+	case params[ParamKubeconfig] != "":
+		config, err = kubeconfigConfig(params[ParamKubeconfig], params[ParamKubeContext])
+	default:
+		config = &rest.Config{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if params[ParamMasterIP] != "" && params[ParamAPIPort] != "" {
+		config.Host = apiScheme(params) + "://" + params[ParamMasterIP] + ":" + params[ParamAPIPort]
+	}
+	if v := params[ParamBearerToken]; v != "" {
+		config.BearerToken = v
+	}
+	if v := params[ParamTokenFile]; v != "" {
+		config.BearerToken = ""
+		config.WrapTransport = tokenFileWrapper(v)
+	}
+	if v := params[ParamClientCert]; v != "" {
+		config.CertFile, config.CertData = v, nil
+	}
+	if v := params[ParamClientKey]; v != "" {
+		config.KeyFile, config.KeyData = v, nil
+	}
+	if v := params[ParamCAFile]; v != "" {
+		config.CAFile, config.CAData = v, nil
+	}
+	if v := params[ParamTLSServerName]; v != "" {
+		config.ServerName = v
+	}
+	if params[ParamInsecure] == "true" {
+		config.Insecure = true
+		config.CAFile, config.CAData = "", nil
+	}
+	if config.Timeout == 0 {
+		config.Timeout = clientTimeout
+	}
+	return config, nil
+}
+
+// NewClientset is NewConfig followed by kubernetes.NewForConfig.
+func NewClientset(params map[string]string) (*kubernetes.Clientset, error) {
+	config, err := NewConfig(params)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// apiScheme defaults to https as soon as any TLS or token setting is present,
+// so records that only carry master_ip and api_port keep using the insecure
+// port.
+func apiScheme(params map[string]string) string {
+	if v := params[ParamAPIScheme]; v != "" {
+		return v
+	}
+	for _, k := range []string{ParamBearerToken, ParamTokenFile, ParamClientCert, ParamCAFile,
+		ParamInsecure, ParamTLSServerName, ParamKubeconfig, ParamInCluster} {
+		if params[k] != "" {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+// inClusterConfig is rest.InClusterConfig with the service account token
+// read through tokenFileWrapper so that rotated tokens are picked up.
+func inClusterConfig() (*rest.Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("in_cluster is set but KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not defined")
+	}
+	config := &rest.Config{
+		Host:          "https://" + host + ":" + port,
+		WrapTransport: tokenFileWrapper(serviceAccountDir + "token"),
+	}
+	if _, err := os.Stat(serviceAccountDir + "ca.crt"); err == nil {
+		config.CAFile = serviceAccountDir + "ca.crt"
+	}
+	return config, nil
+}
+
+type namedCluster struct {
+	Name    string               `json:"name"`
+	Cluster clientcmdapi.Cluster `json:"cluster"`
+}
+type namedAuthInfo struct {
+	Name     string                `json:"name"`
+	AuthInfo clientcmdapi.AuthInfo `json:"user"`
+}
+type namedContext struct {
+	Name    string               `json:"name"`
+	Context clientcmdapi.Context `json:"context"`
+}
+
+// kubeconfigFile is the on-disk (v1) layout of a kubeconfig, which keeps
+// clusters, users and contexts as named lists.
+type kubeconfigFile struct {
+	Clusters       []namedCluster  `json:"clusters"`
+	AuthInfos      []namedAuthInfo `json:"users"`
+	Contexts       []namedContext  `json:"contexts"`
+	CurrentContext string          `json:"current-context"`
+}
+
+func loadKubeconfig(path string) (*clientcmdapi.Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := kubeconfigFile{}
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse kubeconfig %s: %s", path, err.Error())
+	}
+	dir := filepath.Dir(path)
+	config := clientcmdapi.NewConfig()
+	config.CurrentContext = f.CurrentContext
+	for i := range f.Clusters {
+		c := f.Clusters[i].Cluster
+		c.CertificateAuthority = resolvePath(dir, c.CertificateAuthority)
+		config.Clusters[f.Clusters[i].Name] = &c
+	}
+	for i := range f.AuthInfos {
+		a := f.AuthInfos[i].AuthInfo
+		a.ClientCertificate = resolvePath(dir, a.ClientCertificate)
+		a.ClientKey = resolvePath(dir, a.ClientKey)
+		a.TokenFile = resolvePath(dir, a.TokenFile)
+		config.AuthInfos[f.AuthInfos[i].Name] = &a
+	}
+	for i := range f.Contexts {
+		c := f.Contexts[i].Context
+		config.Contexts[f.Contexts[i].Name] = &c
+	}
+	return config, nil
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func kubeconfigConfig(path, contextName string) (*rest.Config, error) {
+	kc, err := loadKubeconfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if contextName == "" {
+		contextName = kc.CurrentContext
+	}
+	context, ok := kc.Contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("context %q not found in kubeconfig %s", contextName, path)
+	}
+	cluster, ok := kc.Clusters[context.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q not found in kubeconfig %s", context.Cluster, path)
+	}
+	config := &rest.Config{Host: cluster.Server}
+	config.Insecure = cluster.InsecureSkipTLSVerify
+	config.CAFile = cluster.CertificateAuthority
+	config.CAData = cluster.CertificateAuthorityData
+	if auth, ok := kc.AuthInfos[context.AuthInfo]; ok {
+		config.CertFile = auth.ClientCertificate
+		config.CertData = auth.ClientCertificateData
+		config.KeyFile = auth.ClientKey
+		config.KeyData = auth.ClientKeyData
+		config.Username = auth.Username
+		config.Password = auth.Password
+		config.Impersonate.UserName = auth.Impersonate
+		switch {
+		case auth.Token != "":
+			config.BearerToken = auth.Token
+		case auth.TokenFile != "":
+			config.WrapTransport = tokenFileWrapper(auth.TokenFile)
+		}
+		if auth.AuthProvider != nil {
+			return nil, fmt.Errorf("auth provider %q in kubeconfig %s is not supported", auth.AuthProvider.Name, path)
+		}
+	}
+	return config, nil
+}
+
+// tokenFile hands out the content of a bearer token file, reading it again
+// whenever its modification time changes.
+type tokenFile struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	token   string
+}
+
+func (t *tokenFile) get() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		return "", err
+	}
+	if t.token != "" && fi.ModTime().Equal(t.modTime) {
+		return t.token, nil
+	}
+	data, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		return "", err
+	}
+	t.token = strings.TrimSpace(string(data))
+	t.modTime = fi.ModTime()
+	return t.token, nil
+}
+
+var (
+	tokenFilesMu sync.Mutex
+	tokenFiles   = map[string]*tokenFile{}
+)
+
+func tokenFileWrapper(path string) func(http.RoundTripper) http.RoundTripper {
+	tokenFilesMu.Lock()
+	t, ok := tokenFiles[path]
+	if !ok {
+		t = &tokenFile{path: path}
+		tokenFiles[path] = t
+	}
+	tokenFilesMu.Unlock()
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &tokenFileRoundTripper{t, rt}
+	}
+}
+
+type tokenFileRoundTripper struct {
+	token *tokenFile
+	rt    http.RoundTripper
+}
+
+func (r *tokenFileRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return r.rt.RoundTrip(req)
+	}
+	token, err := r.token.get()
+	if err != nil {
+		return nil, fmt.Errorf("read bearer token file: %s", err.Error())
+	}
+	clone := new(http.Request)
+	*clone = *req
+	clone.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		clone.Header[k] = v
+	}
+	clone.Header.Set("Authorization", "Bearer "+token)
+	return r.rt.RoundTrip(clone)
+}
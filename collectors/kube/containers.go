@@ -0,0 +1,14 @@
+package kube
+
+import (
+	"strings"
+)
+
+// SplitContainerID splits a container status ID like containerd://<id> into
+// the runtime and the ID. An ID without a runtime prefix has no runtime.
+func SplitContainerID(id string) (runtime, containerID string) {
+	if i := strings.Index(id, "://"); i >= 0 {
+		return id[:i], id[i+3:]
+	}
+	return "", id
+}
@@ -0,0 +1,168 @@
+package kube
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	resourceEvents = "events"
+
+	eventObjectIndex = "object"
+)
+
+// EventKey is what events are counted by.
+type EventKey struct {
+	Type      string
+	Reason    string
+	Kind      string
+	Namespace string
+}
+
+// eventCounter turns the events seen by the informer into counters. An event
+// is updated in place each time it happens again, only the growth of its
+// count field is added so that repeats are not counted twice.
+//
+// The initial list of the informer holds events that happened before it
+// started. They only seed the counter: their count is not added, later
+// updates add the growth past it.
+type eventCounter struct {
+	mu      sync.Mutex
+	counts  map[EventKey]float64
+	synced  cache.InformerSynced
+	started time.Time
+}
+
+func newEventCounter() *eventCounter {
+	return &eventCounter{counts: make(map[EventKey]float64)}
+}
+
+func eventKeyOf(ev *v1.Event) EventKey {
+	return EventKey{ev.Type, ev.Reason, ev.InvolvedObject.Kind, ev.Namespace}
+}
+
+// eventCount is the count of an event, which older API servers leave at 0 for
+// events seen once.
+func eventCount(ev *v1.Event) int32 {
+	if ev.Count < 1 {
+		return 1
+	}
+	return ev.Count
+}
+
+// watch records the informer the counter is fed by, the first time it is
+// called.
+func (e *eventCounter) watch(informer cache.SharedIndexInformer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.synced == nil {
+		e.synced = informer.HasSynced
+		e.started = time.Now()
+	}
+}
+
+// historical tells whether an added event comes from the initial list.
+// Handlers are notified asynchronously, so adds of the initial list may
+// still arrive once the informer has synced; those were last seen before it
+// started.
+func (e *eventCounter) historical(ev *v1.Event) bool {
+	e.mu.Lock()
+	synced, started := e.synced, e.started
+	e.mu.Unlock()
+	return synced == nil || !synced() || ev.LastTimestamp.Time.Before(started)
+}
+
+func (e *eventCounter) OnAdd(obj interface{}) {
+	ev, ok := obj.(*v1.Event)
+	if !ok || e.historical(ev) {
+		return
+	}
+	e.mu.Lock()
+	e.counts[eventKeyOf(ev)] += float64(eventCount(ev))
+	e.mu.Unlock()
+}
+
+func (e *eventCounter) OnUpdate(oldObj, newObj interface{}) {
+	old, ok := oldObj.(*v1.Event)
+	if !ok {
+		return
+	}
+	ev, ok := newObj.(*v1.Event)
+	if !ok {
+		return
+	}
+	delta := eventCount(ev) - eventCount(old)
+	if delta <= 0 {
+		return
+	}
+	e.mu.Lock()
+	e.counts[eventKeyOf(ev)] += float64(delta)
+	e.mu.Unlock()
+}
+
+// OnDelete keeps the counts, events expire on the API server but what they
+// recorded still happened.
+func (e *eventCounter) OnDelete(obj interface{}) {}
+
+func (e *eventCounter) snapshot() map[EventKey]float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	counts := make(map[EventKey]float64, len(e.counts))
+	for k, v := range e.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+func (c *ClusterCache) eventInformer() cache.SharedIndexInformer {
+	informer := c.groupInformer(c.clientset.CoreV1().RESTClient(), resourceEvents, &v1.Event{}, cache.Indexers{
+		eventObjectIndex: func(obj interface{}) ([]string, error) {
+			ev, ok := obj.(*v1.Event)
+			if !ok {
+				return nil, nil
+			}
+			return []string{eventObjectKey(ev.InvolvedObject.Kind, ev.InvolvedObject.Namespace, ev.InvolvedObject.Name)}, nil
+		},
+	}, c.events)
+	c.events.watch(informer)
+	return informer
+}
+
+func eventObjectKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// EventCounts returns the number of events that happened since the cache
+// started watching them, by type, reason, involved object kind and namespace.
+func (c *ClusterCache) EventCounts() (map[EventKey]float64, error) {
+	if err := c.waitForInformer(c.eventInformer(), resourceEvents); err != nil {
+		return nil, err
+	}
+	return c.events.snapshot(), nil
+}
+
+// RecentWarnings counts the Warning events of an object last seen within
+// window, by reason. Nodes are cluster scoped, their namespace is "". It
+// enriches other scrapes, so it fails at once rather than wait for events.
+func (c *ClusterCache) RecentWarnings(kind, namespace, name string, window time.Duration) (map[string]int, error) {
+	informer := c.eventInformer()
+	if err := c.checkSynced(informer, resourceEvents); err != nil {
+		return nil, err
+	}
+	objs, err := informer.GetIndexer().ByIndex(eventObjectIndex, eventObjectKey(kind, namespace, name))
+	if err != nil {
+		return nil, err
+	}
+	warnings := make(map[string]int)
+	for _, obj := range objs {
+		ev := obj.(*v1.Event)
+		if ev.Type != v1.EventTypeWarning || time.Since(ev.LastTimestamp.Time) > window {
+			continue
+		}
+		warnings[ev.Reason] += int(eventCount(ev))
+	}
+	return warnings, nil
+}
@@ -0,0 +1,51 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func testEvent(name string, count int32, last time.Time) *v1.Event {
+	ev := &v1.Event{Type: v1.EventTypeWarning, Reason: "BackOff", Count: count, LastTimestamp: metav1.NewTime(last)}
+	ev.Name, ev.Namespace = name, "default"
+	ev.InvolvedObject.Kind = "Pod"
+	return ev
+}
+
+func TestEventCounterSeedsInitialList(t *testing.T) {
+	started := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	synced := false
+	e := newEventCounter()
+	e.synced = func() bool { return synced }
+	e.started = started
+	key := EventKey{v1.EventTypeWarning, "BackOff", "Pod", "default"}
+
+	// The initial list only seeds the counter.
+	old := testEvent("a", 40, started.Add(-time.Hour))
+	e.OnAdd(old)
+	synced = true
+	// An add of the initial list delivered after the informer synced.
+	e.OnAdd(testEvent("b", 7, started.Add(-time.Minute)))
+	if got := e.snapshot()[key]; got != 0 {
+		t.Fatalf("count after the initial list = %v, want 0", got)
+	}
+
+	// Repeats of a listed event add their growth.
+	e.OnUpdate(old, testEvent("a", 43, started.Add(time.Minute)))
+	// New events add their count.
+	e.OnAdd(testEvent("c", 1, started.Add(2*time.Minute)))
+	if got := e.snapshot()[key]; got != 4 {
+		t.Errorf("count = %v, want 4", got)
+	}
+}
+
+func TestEventCounterWithoutInformer(t *testing.T) {
+	e := newEventCounter()
+	e.OnAdd(testEvent("a", 3, time.Now()))
+	if got := len(e.snapshot()); got != 0 {
+		t.Errorf("counted %d keys before the informer was known", got)
+	}
+}
@@ -0,0 +1,31 @@
+package kube
+
+import (
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// ResourceQuotas and LimitRanges are watched once a scrape asks for them.
+
+func (c *ClusterCache) ResourceQuotas(namespace string) ([]*v1.ResourceQuota, error) {
+	objs, err := c.list(c.clientset.CoreV1().RESTClient(), "resourcequotas", &v1.ResourceQuota{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*v1.ResourceQuota, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*v1.ResourceQuota))
+	}
+	return items, nil
+}
+
+func (c *ClusterCache) LimitRanges(namespace string) ([]*v1.LimitRange, error) {
+	objs, err := c.list(c.clientset.CoreV1().RESTClient(), "limitranges", &v1.LimitRange{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*v1.LimitRange, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*v1.LimitRange))
+	}
+	return items, nil
+}
@@ -0,0 +1,31 @@
+package kube
+
+import (
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// Services and Endpoints are watched once a scrape asks for them.
+
+func (c *ClusterCache) Services(namespace string) ([]*v1.Service, error) {
+	objs, err := c.list(c.clientset.CoreV1().RESTClient(), "services", &v1.Service{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*v1.Service, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*v1.Service))
+	}
+	return items, nil
+}
+
+func (c *ClusterCache) Endpoints(namespace string) ([]*v1.Endpoints, error) {
+	objs, err := c.list(c.clientset.CoreV1().RESTClient(), "endpoints", &v1.Endpoints{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*v1.Endpoints, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*v1.Endpoints))
+	}
+	return items, nil
+}
@@ -0,0 +1,67 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/client-go/pkg/api/v1"
+	storagev1 "k8s.io/client-go/pkg/apis/storage/v1"
+)
+
+// Volumes are watched once a scrape asks for them. PersistentVolumes and
+// PersistentVolumeClaims come from the core group, StorageClasses from
+// storage/v1.
+
+func (c *ClusterCache) PersistentVolumes() ([]*v1.PersistentVolume, error) {
+	objs, err := c.list(c.clientset.CoreV1().RESTClient(), "persistentvolumes", &v1.PersistentVolume{}, "")
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*v1.PersistentVolume, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*v1.PersistentVolume))
+	}
+	return items, nil
+}
+
+func (c *ClusterCache) PersistentVolumeClaims(namespace string) ([]*v1.PersistentVolumeClaim, error) {
+	objs, err := c.list(c.clientset.CoreV1().RESTClient(), "persistentvolumeclaims", &v1.PersistentVolumeClaim{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*v1.PersistentVolumeClaim, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*v1.PersistentVolumeClaim))
+	}
+	return items, nil
+}
+
+// StorageClasses fails fast when storage/v1 is not served. Until their
+// informer has synced they are read straight from the API server, so a
+// service account that may not list them fails the request at once instead
+// of waiting for a sync that never happens.
+func (c *ClusterCache) StorageClasses() ([]*storagev1.StorageClass, error) {
+	if err := c.checkServed(storagev1.SchemeGroupVersion.String(), "storageclasses"); err != nil {
+		return nil, err
+	}
+	client := c.clientset.StorageV1().RESTClient()
+	objs, err := c.listIfSynced(client, "storageclasses", &storagev1.StorageClass{}, "")
+	if err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+		defer cancel()
+		list := &storagev1.StorageClassList{}
+		if err := client.Get().Resource("storageclasses").Context(ctx).Do().Into(list); err != nil {
+			return nil, fmt.Errorf("list storageclasses on %s: %s", c.host, err.Error())
+		}
+		items := make([]*storagev1.StorageClass, 0, len(list.Items))
+		for i := range list.Items {
+			items = append(items, &list.Items[i])
+		}
+		return items, nil
+	}
+	items := make([]*storagev1.StorageClass, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*storagev1.StorageClass))
+	}
+	return items, nil
+}
@@ -0,0 +1,67 @@
+package kube
+
+import (
+	appsv1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+	extv1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// Workload controllers are watched once a scrape asks for them. Deployments,
+// DaemonSets and ReplicaSets come from extensions/v1beta1, StatefulSets from
+// apps/v1beta1.
+
+func (c *ClusterCache) Deployments(namespace string) ([]*extv1beta1.Deployment, error) {
+	objs, err := c.list(c.clientset.ExtensionsV1beta1().RESTClient(), "deployments", &extv1beta1.Deployment{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*extv1beta1.Deployment, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*extv1beta1.Deployment))
+	}
+	return items, nil
+}
+
+func (c *ClusterCache) DaemonSets(namespace string) ([]*extv1beta1.DaemonSet, error) {
+	objs, err := c.list(c.clientset.ExtensionsV1beta1().RESTClient(), "daemonsets", &extv1beta1.DaemonSet{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*extv1beta1.DaemonSet, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*extv1beta1.DaemonSet))
+	}
+	return items, nil
+}
+
+func (c *ClusterCache) ReplicaSets(namespace string) ([]*extv1beta1.ReplicaSet, error) {
+	return replicaSetItems(c.list(c.clientset.ExtensionsV1beta1().RESTClient(), "replicasets", &extv1beta1.ReplicaSet{}, namespace))
+}
+
+// ReplicaSetsIfSynced does not wait for the ReplicaSets to be listed, for
+// scrapes that only join them to other objects.
+func (c *ClusterCache) ReplicaSetsIfSynced(namespace string) ([]*extv1beta1.ReplicaSet, error) {
+	return replicaSetItems(c.listIfSynced(c.clientset.ExtensionsV1beta1().RESTClient(), "replicasets", &extv1beta1.ReplicaSet{}, namespace))
+}
+
+func replicaSetItems(objs []interface{}, err error) ([]*extv1beta1.ReplicaSet, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*extv1beta1.ReplicaSet, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*extv1beta1.ReplicaSet))
+	}
+	return items, nil
+}
+
+func (c *ClusterCache) StatefulSets(namespace string) ([]*appsv1beta1.StatefulSet, error) {
+	objs, err := c.list(c.clientset.AppsV1beta1().RESTClient(), "statefulsets", &appsv1beta1.StatefulSet{}, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*appsv1beta1.StatefulSet, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.(*appsv1beta1.StatefulSet))
+	}
+	return items, nil
+}
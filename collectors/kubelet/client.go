@@ -0,0 +1,142 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"container-exporter/collectors/kube"
+	"k8s.io/client-go/rest"
+)
+
+// Keys of ConnectInfoData.Params_maps that select where container and node
+// stats come from.
+const (
+	ParamStatsSource     = "stats_source"
+	ParamKubeletPort     = "kubelet_port"
+	ParamKubeletScheme   = "kubelet_scheme"
+	ParamKubeletInsecure = "kubelet_insecure_skip_tls_verify"
+)
+
+// Stats sources. SourceKubelet talks to the kubelet on node_ip directly,
+// SourceKubeletProxy goes through the API server nodes/{name}/proxy.
+const (
+	SourceCadvisor     = "cadvisor"
+	SourceKubelet      = "kubelet"
+	SourceKubeletProxy = "kubelet_proxy"
+)
+
+const (
+	defaultKubeletPort = "10250"
+	readOnlyPort       = "10255"
+	requestTimeout     = 30 * time.Second
+)
+
+// Source returns the stats source of a target. Records written before the
+// summary API was supported only carry cadvisor_port and keep using it.
+func Source(params map[string]string) string {
+	if v := params[ParamStatsSource]; v != "" {
+		return v
+	}
+	if params["cadvisor_port"] != "" {
+		return SourceCadvisor
+	}
+	return SourceKubeletProxy
+}
+
+// GetSummary fetches /stats/summary of a node through the given source.
+func GetSummary(params map[string]string, source, nodeIP, nodeName string) (*Summary, error) {
+	return GetSummaryContext(context.Background(), params, source, nodeIP, nodeName)
+}
+
+// GetSummaryContext is GetSummary giving up when ctx is done.
+func GetSummaryContext(ctx context.Context, params map[string]string, source, nodeIP, nodeName string) (*Summary, error) {
+	var data []byte
+	var err error
+	switch source {
+	case SourceKubelet:
+		data, err = getDirect(ctx, params, nodeIP, "/stats/summary")
+	case SourceKubeletProxy:
+		data, err = getProxy(ctx, params, nodeName, "stats", "summary")
+	default:
+		return nil, fmt.Errorf("stats source %q does not serve the summary API", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	summary := &Summary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, fmt.Errorf("decode summary of node %s: %s", nodeName, err.Error())
+	}
+	return summary, nil
+}
+
+func getDirect(ctx context.Context, params map[string]string, nodeIP, path string) ([]byte, error) {
+	if nodeIP == "" {
+		return nil, fmt.Errorf("node_ip is required to reach the kubelet directly")
+	}
+	port := params[ParamKubeletPort]
+	if port == "" {
+		port = defaultKubeletPort
+	}
+	scheme := params[ParamKubeletScheme]
+	if scheme == "" {
+		scheme = "https"
+		if port == readOnlyPort {
+			scheme = "http"
+		}
+	}
+	config, err := kube.AuthConfig(params)
+	if err != nil {
+		return nil, err
+	}
+	config.Host = scheme + "://" + nodeIP + ":" + port
+	// Kubelet serving certificates are often self-signed and not issued by
+	// the cluster CA.
+	if params[ParamKubeletInsecure] == "true" {
+		config.Insecure = true
+		config.CAFile, config.CAData = "", nil
+	}
+	rt, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: rt, Timeout: requestTimeout}
+	req, err := http.NewRequest("GET", config.Host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s%s: %s", config.Host, path, resp.Status)
+	}
+	return body, nil
+}
+
+func getProxy(ctx context.Context, params map[string]string, nodeName string, suffix ...string) ([]byte, error) {
+	if nodeName == "" {
+		return nil, fmt.Errorf("node name is required to reach the kubelet through the API server")
+	}
+	clientset, err := kube.NewClientset(params)
+	if err != nil {
+		return nil, err
+	}
+	return clientset.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix(suffix...).
+		Context(ctx).
+		DoRaw()
+}
@@ -0,0 +1,130 @@
+package kubelet
+
+import (
+	"time"
+)
+
+// Summary is the part of the kubelet /stats/summary response (stats/v1alpha1)
+// read by the collectors.
+type Summary struct {
+	Node NodeStats  `json:"node"`
+	Pods []PodStats `json:"pods"`
+}
+
+type NodeStats struct {
+	NodeName         string           `json:"nodeName"`
+	SystemContainers []ContainerStats `json:"systemContainers,omitempty"`
+	StartTime        time.Time        `json:"startTime"`
+	CPU              *CPUStats        `json:"cpu,omitempty"`
+	Memory           *MemoryStats     `json:"memory,omitempty"`
+	Network          *NetworkStats    `json:"network,omitempty"`
+	Fs               *FsStats         `json:"fs,omitempty"`
+	Runtime          *RuntimeStats    `json:"runtime,omitempty"`
+}
+
+type RuntimeStats struct {
+	ImageFs *FsStats `json:"imageFs,omitempty"`
+}
+
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
+}
+
+type PodStats struct {
+	PodRef           PodReference     `json:"podRef"`
+	StartTime        time.Time        `json:"startTime"`
+	Containers       []ContainerStats `json:"containers"`
+	Network          *NetworkStats    `json:"network,omitempty"`
+	VolumeStats      []VolumeStats    `json:"volume,omitempty"`
+	EphemeralStorage *FsStats         `json:"ephemeral-storage,omitempty"`
+}
+
+type ContainerStats struct {
+	Name      string       `json:"name"`
+	StartTime time.Time    `json:"startTime"`
+	CPU       *CPUStats    `json:"cpu,omitempty"`
+	Memory    *MemoryStats `json:"memory,omitempty"`
+	Rootfs    *FsStats     `json:"rootfs,omitempty"`
+	Logs      *FsStats     `json:"logs,omitempty"`
+}
+
+type CPUStats struct {
+	Time                 time.Time `json:"time"`
+	UsageNanoCores       *uint64   `json:"usageNanoCores,omitempty"`
+	UsageCoreNanoSeconds *uint64   `json:"usageCoreNanoSeconds,omitempty"`
+}
+
+type MemoryStats struct {
+	Time            time.Time `json:"time"`
+	AvailableBytes  *uint64   `json:"availableBytes,omitempty"`
+	UsageBytes      *uint64   `json:"usageBytes,omitempty"`
+	WorkingSetBytes *uint64   `json:"workingSetBytes,omitempty"`
+	RSSBytes        *uint64   `json:"rssBytes,omitempty"`
+	PageFaults      *uint64   `json:"pageFaults,omitempty"`
+	MajorPageFaults *uint64   `json:"majorPageFaults,omitempty"`
+}
+
+type InterfaceStats struct {
+	Name     string  `json:"name"`
+	RxBytes  *uint64 `json:"rxBytes,omitempty"`
+	RxErrors *uint64 `json:"rxErrors,omitempty"`
+	TxBytes  *uint64 `json:"txBytes,omitempty"`
+	TxErrors *uint64 `json:"txErrors,omitempty"`
+}
+
+type NetworkStats struct {
+	Time time.Time `json:"time"`
+	InterfaceStats
+	Interfaces []InterfaceStats `json:"interfaces,omitempty"`
+}
+
+type FsStats struct {
+	Time           time.Time `json:"time"`
+	AvailableBytes *uint64   `json:"availableBytes,omitempty"`
+	CapacityBytes  *uint64   `json:"capacityBytes,omitempty"`
+	UsedBytes      *uint64   `json:"usedBytes,omitempty"`
+	InodesFree     *uint64   `json:"inodesFree,omitempty"`
+	Inodes         *uint64   `json:"inodes,omitempty"`
+	InodesUsed     *uint64   `json:"inodesUsed,omitempty"`
+}
+
+type PVCReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type VolumeStats struct {
+	FsStats
+	Name   string        `json:"name,omitempty"`
+	PVCRef *PVCReference `json:"pvcRef,omitempty"`
+}
+
+// Value dereferences an optional summary field, missing values read as 0.
+func Value(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// Pod returns the stats of the pod namespace/name, or nil.
+func (s *Summary) Pod(namespace, name string) *PodStats {
+	for i := range s.Pods {
+		if s.Pods[i].PodRef.Namespace == namespace && s.Pods[i].PodRef.Name == name {
+			return &s.Pods[i]
+		}
+	}
+	return nil
+}
+
+// Container returns the stats of the named container of the pod, or nil.
+func (p *PodStats) Container(name string) *ContainerStats {
+	for i := range p.Containers {
+		if p.Containers[i].Name == name {
+			return &p.Containers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package collectors
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"container-exporter/collectors/kube"
+	"container-exporter/collectors/kubelet"
+	"github.com/google/cadvisor/client"
+	"github.com/google/cadvisor/client/v2"
+	"github.com/google/cadvisor/info/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// containerSource is where K8sContainerCollector reads the spec and stats of
+// a container and the machine it runs on.
+type containerSource interface {
+	containerInfo(pod *apiv1.Pod, status *apiv1.ContainerStatus) (*v1.ContainerInfo, error)
+	// containerInfos looks up the containers of all the pods at once, keyed
+	// by status ContainerID. Containers the source does not know are left out.
+	containerInfos(pods []*apiv1.Pod) (map[string]*v1.ContainerInfo, error)
+	machineInfo() (*v1.MachineInfo, error)
+}
+
+// newContainerSource picks the stats source configured for the target. A
+// failing kubelet source falls back to cadvisor when cadvisor_port is set.
+func newContainerSource(params map[string]string, nodeIP string, node *apiv1.Node) (containerSource, error) {
+	source := kubelet.Source(params)
+	if source != kubelet.SourceCadvisor {
+		summary, err := kubelet.GetSummary(params, source, nodeIP, node.Name)
+		if err == nil {
+			return &summarySource{summary, node}, nil
+		}
+		if params["cadvisor_port"] == "" {
+			return nil, err
+		}
+		log.Printf("get summary of node %s error, falling back to cadvisor: %s", node.Name, err.Error())
+	}
+	c, err := client.NewClient("http://" + nodeIP + ":" + params["cadvisor_port"])
+	if err != nil {
+		return nil, err
+	}
+	return &cadvisorSource{c}, nil
+}
+
+type cadvisorSource struct {
+	client *client.Client
+}
+
+func (s *cadvisorSource) containerInfo(pod *apiv1.Pod, status *apiv1.ContainerStatus) (*v1.ContainerInfo, error) {
+	// Two samples let the collector derive the cpu usage against the limit.
+	request := v1.ContainerInfoRequest{NumStats: 2}
+	id := containerIDOf(status.ContainerID)
+	if containerRuntime(status.ContainerID) == "docker" {
+		// The docker handler also resolves the docker name into Aliases.
+		cinfo, err := s.client.DockerContainer(id, &request)
+		if err == nil {
+			return &cinfo, nil
+		}
+	}
+	var lastErr error
+	for _, path := range cgroupPaths(pod, status) {
+		cinfo, err := s.client.ContainerInfo(path, &request)
+		if err == nil {
+			return cinfo, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("container %s not found in cadvisor: %s", id, lastErr)
+}
+
+func (s *cadvisorSource) containerInfos(pods []*apiv1.Pod) (map[string]*v1.ContainerInfo, error) {
+	request := v1.ContainerInfoRequest{NumStats: 2}
+	// A single recursive request from the root cgroup returns every
+	// container of the node.
+	all, err := s.client.SubcontainersInfo("/", &request)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*v1.ContainerInfo, len(all))
+	for i := range all {
+		byName[all[i].Name] = &all[i]
+		for _, alias := range all[i].Aliases {
+			byName[alias] = &all[i]
+		}
+	}
+	infos := make(map[string]*v1.ContainerInfo)
+	for _, pod := range pods {
+		for i := range pod.Status.ContainerStatuses {
+			status := &pod.Status.ContainerStatuses[i]
+			if status.ContainerID == "" {
+				continue
+			}
+			candidates := append(cgroupPaths(pod, status), containerIDOf(status.ContainerID))
+			for _, name := range candidates {
+				if cinfo, ok := byName[name]; ok {
+					infos[status.ContainerID] = cinfo
+					break
+				}
+			}
+		}
+	}
+	return infos, nil
+}
+
+// cgroupPaths lists where the kubelet may have put the cgroup of a container,
+// for the cgroupfs and systemd cgroup drivers and the scope prefixes used by
+// docker, containerd and CRI-O.
+func cgroupPaths(pod *apiv1.Pod, status *apiv1.ContainerStatus) []string {
+	id := containerIDOf(status.ContainerID)
+	uid := string(pod.UID)
+	qos := strings.ToLower(string(pod.Status.QOSClass))
+	if pod.Status.QOSClass == apiv1.PodQOSGuaranteed {
+		qos = ""
+	}
+	var prefix string
+	switch containerRuntime(status.ContainerID) {
+	case "containerd":
+		prefix = "cri-containerd"
+	case "cri-o":
+		prefix = "crio"
+	default:
+		prefix = "docker"
+	}
+	cgroupfs := "/kubepods/"
+	slice := "/kubepods.slice/"
+	slicePod := "kubepods-pod"
+	if qos != "" {
+		cgroupfs += qos + "/"
+		slice += "kubepods-" + qos + ".slice/"
+		slicePod = "kubepods-" + qos + "-pod"
+	}
+	cgroupfs += "pod" + uid + "/"
+	slice += slicePod + strings.Replace(uid, "-", "_", -1) + ".slice/"
+	return []string{
+		cgroupfs + id,
+		cgroupfs + prefix + "-" + id,
+		slice + prefix + "-" + id + ".scope",
+		"/" + prefix + "/" + id,
+	}
+}
+
+func (s *cadvisorSource) machineInfo() (*v1.MachineInfo, error) {
+	return s.client.MachineInfo()
+}
+
+// summarySource serves containers out of a kubelet summary. The summary has
+// no cgroup spec, so it is rebuilt from the pod resources the same way the
+// kubelet sets the cgroup limits.
+type summarySource struct {
+	summary *kubelet.Summary
+	node    *apiv1.Node
+}
+
+const (
+	cfsPeriod      = 100000
+	sharesPerCPU   = 1024
+	minShares      = 2
+	milliCPUToCPU  = 1000
+	quotaPerMilliC = cfsPeriod / milliCPUToCPU
+)
+
+func (s *summarySource) containerInfo(pod *apiv1.Pod, status *apiv1.ContainerStatus) (*v1.ContainerInfo, error) {
+	ps := s.summary.Pod(pod.Namespace, pod.Name)
+	if ps == nil {
+		return nil, fmt.Errorf("pod %s/%s not in summary of node %s", pod.Namespace, pod.Name, s.node.Name)
+	}
+	cs := ps.Container(status.Name)
+	if cs == nil {
+		return nil, fmt.Errorf("container %s of pod %s/%s not in summary of node %s", status.Name, pod.Namespace, pod.Name, s.node.Name)
+	}
+	id := containerIDOf(status.ContainerID)
+	info := &v1.ContainerInfo{
+		ContainerReference: v1.ContainerReference{Id: id, Name: id},
+		Spec:               v1.ContainerSpec{CreationTime: cs.StartTime},
+	}
+	if c := podContainer(pod, status.Name); c != nil {
+		info.Spec.HasCpu = true
+		info.Spec.Cpu.Period = cfsPeriod
+		info.Spec.Cpu.Limit = minShares
+		if q, ok := c.Resources.Requests[apiv1.ResourceCPU]; ok && q.MilliValue()*sharesPerCPU/milliCPUToCPU > minShares {
+			info.Spec.Cpu.Limit = uint64(q.MilliValue() * sharesPerCPU / milliCPUToCPU)
+		}
+		if q, ok := c.Resources.Limits[apiv1.ResourceCPU]; ok {
+			info.Spec.Cpu.Quota = uint64(q.MilliValue() * quotaPerMilliC)
+		}
+		info.Spec.HasMemory = true
+		info.Spec.Memory.Limit = ^uint64(0)
+		if q, ok := c.Resources.Limits[apiv1.ResourceMemory]; ok {
+			info.Spec.Memory.Limit = uint64(q.Value())
+		}
+		info.Spec.Memory.SwapLimit = ^uint64(0)
+	}
+	stats := &v1.ContainerStats{Timestamp: time.Now()}
+	if cs.CPU != nil {
+		stats.Timestamp = cs.CPU.Time
+		stats.Cpu.Usage.Total = kubelet.Value(cs.CPU.UsageCoreNanoSeconds)
+	}
+	if cs.Memory != nil {
+		stats.Memory.Usage = kubelet.Value(cs.Memory.UsageBytes)
+		stats.Memory.WorkingSet = kubelet.Value(cs.Memory.WorkingSetBytes)
+		stats.Memory.RSS = kubelet.Value(cs.Memory.RSSBytes)
+		stats.Memory.ContainerData.Pgfault = kubelet.Value(cs.Memory.PageFaults)
+		stats.Memory.ContainerData.Pgmajfault = kubelet.Value(cs.Memory.MajorPageFaults)
+	}
+	if ps.Network != nil {
+		// Containers of a pod share its network namespace, the summary only
+		// reports it per pod.
+		ifaces := ps.Network.Interfaces
+		if len(ifaces) == 0 && ps.Network.Name != "" {
+			ifaces = []kubelet.InterfaceStats{ps.Network.InterfaceStats}
+		}
+		for _, i := range ifaces {
+			stats.Network.Interfaces = append(stats.Network.Interfaces, v1.InterfaceStats{
+				Name:     i.Name,
+				RxBytes:  kubelet.Value(i.RxBytes),
+				RxErrors: kubelet.Value(i.RxErrors),
+				TxBytes:  kubelet.Value(i.TxBytes),
+				TxErrors: kubelet.Value(i.TxErrors),
+			})
+		}
+	}
+	if cs.Rootfs != nil {
+		stats.Filesystem = append(stats.Filesystem, summaryFsStats("rootfs", cs.Rootfs))
+	}
+	if cs.Logs != nil {
+		stats.Filesystem = append(stats.Filesystem, summaryFsStats("logs", cs.Logs))
+	}
+	info.Stats = []*v1.ContainerStats{stats}
+	// The summary has a single sample but already carries the cpu rate.
+	// A sample one second earlier, UsageNanoCores behind, lets cpuLimitUsage
+	// relate that rate to the cpu limit as it does for cadvisor.
+	if cs.CPU != nil && cs.CPU.UsageNanoCores != nil && stats.Cpu.Usage.Total >= *cs.CPU.UsageNanoCores {
+		previous := &v1.ContainerStats{Timestamp: stats.Timestamp.Add(-time.Second)}
+		previous.Cpu.Usage.Total = stats.Cpu.Usage.Total - *cs.CPU.UsageNanoCores
+		info.Stats = []*v1.ContainerStats{previous, stats}
+	}
+	return info, nil
+}
+
+func (s *summarySource) containerInfos(pods []*apiv1.Pod) (map[string]*v1.ContainerInfo, error) {
+	infos := make(map[string]*v1.ContainerInfo)
+	for _, pod := range pods {
+		for i := range pod.Status.ContainerStatuses {
+			status := &pod.Status.ContainerStatuses[i]
+			if status.ContainerID == "" {
+				continue
+			}
+			if cinfo, err := s.containerInfo(pod, status); err == nil {
+				infos[status.ContainerID] = cinfo
+			}
+		}
+	}
+	return infos, nil
+}
+
+func summaryFsStats(device string, fs *kubelet.FsStats) v1.FsStats {
+	return v1.FsStats{
+		Device:     device,
+		Limit:      kubelet.Value(fs.CapacityBytes),
+		Usage:      kubelet.Value(fs.UsedBytes),
+		Available:  kubelet.Value(fs.AvailableBytes),
+		HasInodes:  fs.Inodes != nil,
+		Inodes:     kubelet.Value(fs.Inodes),
+		InodesFree: kubelet.Value(fs.InodesFree),
+	}
+}
+
+func (s *summarySource) machineInfo() (*v1.MachineInfo, error) {
+	return &v1.MachineInfo{
+		NumCores:       int(s.node.Status.Capacity.Cpu().Value()),
+		MemoryCapacity: uint64(s.node.Status.Capacity.Memory().Value()),
+	}, nil
+}
+
+// nodeUsage is what K8sNodeCollector reads from the stats source.
+type nodeUsage struct {
+	cpuUsage    float64
+	memoryUsed  float64
+	filesystems []nodeFilesystem
+}
+
+type nodeFilesystem struct {
+	device   string
+	capacity float64
+	used     float64
+	avail    float64
+}
+
+// getNodeUsage reads the node usage through the stats source configured for
+// the target, falling back to cadvisor like newContainerSource.
+func getNodeUsage(params map[string]string, nodeIP string, node *apiv1.Node) (*nodeUsage, error) {
+	source := kubelet.Source(params)
+	if source != kubelet.SourceCadvisor {
+		summary, err := kubelet.GetSummary(params, source, nodeIP, node.Name)
+		if err == nil {
+			return summaryNodeUsage(summary, node), nil
+		}
+		if params["cadvisor_port"] == "" {
+			return nil, err
+		}
+		log.Printf("get summary of node %s error, falling back to cadvisor: %s", node.Name, err.Error())
+	}
+	return cadvisorNodeUsage(nodeIP+":"+params["cadvisor_port"], node)
+}
+
+func cadvisorNodeUsage(cadvisorendpoint string, node *apiv1.Node) (*nodeUsage, error) {
+	client, err := v2.NewClient("http://" + cadvisorendpoint)
+	if err != nil {
+		return nil, err
+	}
+	ms, err := client.MachineStats()
+	if err != nil {
+		return nil, err
+	}
+	length := len(ms)
+	if length < 2 {
+		return nil, fmt.Errorf("cadvisor %s returned %d machine stats, need 2", cadvisorendpoint, length)
+	}
+	latest := ms[length-1]       //倒数第一个
+	secondlatest := ms[length-2] //倒数第二个
+	deltatime := latest.Timestamp.UnixNano() - secondlatest.Timestamp.UnixNano()
+	deltacputime := int64(latest.Cpu.Usage.Total - secondlatest.Cpu.Usage.Total)
+	core := node.Status.Capacity.Cpu().Value()
+	usage := &nodeUsage{
+		cpuUsage:   float64(100*deltacputime) / float64(core*deltatime),
+		memoryUsed: float64(latest.Memory.Usage),
+	}
+	for _, state := range latest.Filesystem {
+		usage.filesystems = append(usage.filesystems, nodeFilesystem{
+			device:   state.Device,
+			capacity: float64(kubelet.Value(state.Capacity)),
+			used:     float64(kubelet.Value(state.Usage)),
+			avail:    float64(kubelet.Value(state.Available)),
+		})
+	}
+	return usage, nil
+}
+
+func summaryNodeUsage(summary *kubelet.Summary, node *apiv1.Node) *nodeUsage {
+	usage := &nodeUsage{}
+	core := node.Status.Capacity.Cpu().Value()
+	if cpu := summary.Node.CPU; cpu != nil && core > 0 {
+		usage.cpuUsage = 100 * float64(kubelet.Value(cpu.UsageNanoCores)) / float64(core*int64(time.Second))
+	}
+	if memory := summary.Node.Memory; memory != nil {
+		usage.memoryUsed = float64(kubelet.Value(memory.UsageBytes))
+	}
+	if fs := summary.Node.Fs; fs != nil {
+		usage.filesystems = append(usage.filesystems, summaryNodeFilesystem("rootfs", fs))
+	}
+	if rt := summary.Node.Runtime; rt != nil && rt.ImageFs != nil {
+		usage.filesystems = append(usage.filesystems, summaryNodeFilesystem("imagefs", rt.ImageFs))
+	}
+	return usage
+}
+
+func summaryNodeFilesystem(device string, fs *kubelet.FsStats) nodeFilesystem {
+	return nodeFilesystem{
+		device:   device,
+		capacity: float64(kubelet.Value(fs.CapacityBytes)),
+		used:     float64(kubelet.Value(fs.UsedBytes)),
+		avail:    float64(kubelet.Value(fs.AvailableBytes)),
+	}
+}
+
+// containerRuntime returns the runtime prefix of a container status ID, e.g.
+// docker, containerd or cri-o.
+func containerRuntime(id string) string {
+	runtime, _ := kube.SplitContainerID(id)
+	return runtime
+}
+
+// containerIDOf strips the runtime prefix (docker://, containerd://, ...)
+// from a container status ID.
+func containerIDOf(id string) string {
+	_, containerID := kube.SplitContainerID(id)
+	return containerID
+}
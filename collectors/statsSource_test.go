@@ -0,0 +1,54 @@
+package collectors
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"container-exporter/collectors/kubelet"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+func TestSummaryCPULimitUsage(t *testing.T) {
+	usageNanoCores := uint64(250000000)
+	usageCoreNanoSeconds := uint64(90 * time.Second)
+	summary := &kubelet.Summary{Pods: []kubelet.PodStats{{
+		PodRef: kubelet.PodReference{Name: "web-0", Namespace: "default"},
+		Containers: []kubelet.ContainerStats{{
+			Name: "web",
+			CPU: &kubelet.CPUStats{
+				Time:                 time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC),
+				UsageNanoCores:       &usageNanoCores,
+				UsageCoreNanoSeconds: &usageCoreNanoSeconds,
+			},
+		}},
+	}}}
+	pod := &apiv1.Pod{}
+	pod.Name, pod.Namespace = "web-0", "default"
+	pod.Spec.Containers = []apiv1.Container{{Name: "web"}}
+	status := &apiv1.ContainerStatus{Name: "web", ContainerID: "docker://abc"}
+	source := &summarySource{summary: summary, node: &apiv1.Node{}}
+
+	cinfo, err := source.containerInfo(pod, status)
+	if err != nil {
+		t.Fatalf("containerInfo: %s", err)
+	}
+	if _, ok := cpuLimitUsage(cinfo); ok {
+		t.Errorf("cpuLimitUsage without a cpu limit succeeded")
+	}
+
+	// Half a core of limit, a quarter of a core used.
+	pod.Spec.Containers[0].Resources.Limits = apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("500m")}
+	cinfo, err = source.containerInfo(pod, status)
+	if err != nil {
+		t.Fatalf("containerInfo: %s", err)
+	}
+	usage, ok := cpuLimitUsage(cinfo)
+	if !ok || math.Abs(usage-50) > 1e-9 {
+		t.Errorf("cpuLimitUsage = %v, %v, want 50, true", usage, ok)
+	}
+	if total := cinfo.Stats[len(cinfo.Stats)-1].Cpu.Usage.Total; total != usageCoreNanoSeconds {
+		t.Errorf("latest cpu usage = %d, want %d", total, usageCoreNanoSeconds)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 	"encoding/json"
+	"fmt"
 	"database/sql"
 	"github.com/coreos/etcd/client"
 	"github.com/ghodss/yaml"
@@ -14,8 +15,6 @@ import (
 	"strings"
 )
 
-var db *sql.DB
-
 type Spec struct {
 	Ports     map[string]string `yaml:"ports"`
 	Selector  map[string]string `yaml:"selector"`
@@ -67,7 +66,6 @@ func readEtcdInfo(cfg client.Config, servicename string) string {
 
 }
 func GetDBHandle() *sql.DB {
-	var err error
 	cfg := client.Config{
 		Endpoints:               []string{"http://" + os.Getenv("ETCD_ENDPOINT")},
 		Transport:               client.DefaultTransport,
@@ -82,9 +80,10 @@ func GetDBHandle() *sql.DB {
 	ip := readEtcdInfo(cfg, servicename)
 	dsn := DBUsername + ":" + DBPassword + "@(" + ip + ":" + serviceport + ")/" + DBDatabase
 	//dsn := DBUsername + ":" + DBPassword + "@(" + DBEndpoint + ")/" + DBDatabase
-	db, err = sql.Open("mysql", dsn)
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		log.Printf("get DB handle error: %v", err)
+		return nil
 	}
 	db.SetMaxOpenConns(100)
 	db.SetConnMaxLifetime(28000 * time.Second)
@@ -94,8 +93,25 @@ func GetDBHandle() *sql.DB {
 	}
 	return db
 }
-func GetMonitorInfo(id string) ConnectInfoData {
-	info := queryConnectInfo(id)
+// MySQLStore reads targets from the tbl_monitor_record table of the
+// management database.
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore() (*MySQLStore, error) {
+	handle := GetDBHandle()
+	if handle == nil {
+		return nil, fmt.Errorf("cannot open management database")
+	}
+	return &MySQLStore{handle}, nil
+}
+
+func (s *MySQLStore) GetMonitorInfo(id string) (ConnectInfoData, error) {
+	info, err := s.queryConnectInfo(id)
+	if err != nil {
+		return ConnectInfoData{}, err
+	}
 	m := info.m_info
 	m_info_map := make(map[string]string)
 	if len(m) != 0 {
@@ -108,20 +124,21 @@ func GetMonitorInfo(id string) ConnectInfoData {
 		info.ip,
 		m_info_map,
 	}
-	return con_info_data
+	return con_info_data, nil
 }
-func queryConnectInfo(id string) ConnectInfo {
-	rows, err := db.Query("select ip,monitor_info from tbl_monitor_record where uuid=?", id)
+func (s *MySQLStore) queryConnectInfo(id string) (ConnectInfo, error) {
+	info := ConnectInfo{}
+	rows, err := s.db.Query("select ip,monitor_info from tbl_monitor_record where uuid=?", id)
 	if err != nil {
 		log.Printf("query error")
+		return info, err
 	}
-	info := ConnectInfo{}
+	defer rows.Close()
 	for rows.Next() {
 		err = rows.Scan(&info.ip, &info.m_info)
 	}
-	defer rows.Close()
-	return info
+	return info, err
 }
-func CloseDBHandle() {
-	db.Close()
+func (s *MySQLStore) Close() error {
+	return s.db.Close()
 }
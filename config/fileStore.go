@@ -0,0 +1,282 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"gopkg.in/fsnotify/fsnotify.v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// targetsFile is the layout of the file read by FileStore. Both YAML and
+// JSON are accepted:
+//
+//	targets:
+//	  <uuid>:
+//	    ip: 10.0.0.1
+//	    params:
+//	      master_ip: 10.0.0.1
+//	      api_port: "8080"
+type targetsFile struct {
+	Targets map[string]fileTarget `json:"targets"`
+}
+
+type fileTarget struct {
+	IP     string            `json:"ip"`
+	Params map[string]string `json:"params"`
+}
+
+// params keys understood by the collectors, with the check applied to the
+// value.
+var knownParams = map[string]func(string) error{
+	"master_ip":     checkHost,
+	"api_port":      checkPort,
+	"node_ip":       checkHost,
+	"cadvisor_port": checkPort,
+	"container_id":  checkNotEmpty,
+	"pod_name":      checkNotEmpty,
+	"pod_namespace": checkNotEmpty,
+	"node_name":     checkNotEmpty,
+
+	"api_scheme":               checkScheme,
+	"kubeconfig":               checkNotEmpty,
+	"kube_context":             checkNotEmpty,
+	"in_cluster":               checkBool,
+	"bearer_token":             checkNotEmpty,
+	"bearer_token_file":        checkNotEmpty,
+	"client_cert":              checkNotEmpty,
+	"client_key":               checkNotEmpty,
+	"ca_file":                  checkNotEmpty,
+	"insecure_skip_tls_verify": checkBool,
+	"tls_server_name":          checkNotEmpty,
+
+	"stats_source":                     checkStatsSource,
+	"kubelet_port":                     checkPort,
+	"kubelet_scheme":                   checkScheme,
+	"kubelet_insecure_skip_tls_verify": checkBool,
+
+	"namespace":      checkNotEmpty,
+	"label_selector": checkSelector,
+	"service_probe":  checkBool,
+}
+
+// FileStore serves targets from a YAML or JSON file. The file is reloaded
+// when it changes on disk or when the process receives SIGHUP.
+type FileStore struct {
+	*MemoryStore
+	path      string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("targets file must be specified for the file store")
+	}
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path, done: make(chan struct{})}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	if err := s.watch(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the file again and swaps in its targets. Invalid entries are
+// logged and skipped; if the file cannot be read or parsed the targets
+// loaded before are kept.
+func (s *FileStore) Reload() error {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	f := targetsFile{}
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parse %s: %s", s.path, err.Error())
+	}
+	targets := make(map[string]ConnectInfoData, len(f.Targets))
+	for id, t := range f.Targets {
+		if err := validateTarget(id, t); err != nil {
+			log.Printf("%s: rejecting target %q: %s", s.path, id, err.Error())
+			continue
+		}
+		params := t.Params
+		if params == nil {
+			params = map[string]string{}
+		}
+		targets[id] = ConnectInfoData{t.IP, params}
+	}
+	s.replace(targets)
+	log.Printf("loaded %d of %d targets from %s", len(targets), len(f.Targets), s.path)
+	return nil
+}
+
+func (s *FileStore) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the directory rather than the file so that editors replacing the
+	// file and ConfigMap symlink swaps are noticed too.
+	dir := filepath.Dir(s.path)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(hup)
+		// Writes usually come in bursts, reload once they settle.
+		var pending <-chan time.Time
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-hup:
+				s.reload("SIGHUP")
+			case event := <-watcher.Events:
+				name := filepath.Base(event.Name)
+				if name == filepath.Base(s.path) || name == "..data" {
+					pending = time.After(500 * time.Millisecond)
+				}
+			case <-pending:
+				pending = nil
+				s.reload("file change")
+			case err := <-watcher.Errors:
+				log.Printf("watch %s error: %s", dir, err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *FileStore) reload(reason string) {
+	log.Printf("reloading %s on %s", s.path, reason)
+	if err := s.Reload(); err != nil {
+		log.Printf("reload %s error, keeping previous targets: %s", s.path, err.Error())
+	}
+}
+
+// Close stops watching the file. It is safe to call more than once.
+func (s *FileStore) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+func validateTarget(id string, t fileTarget) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("target id must not be empty")
+	}
+	if t.IP != "" {
+		if err := checkHost(t.IP); err != nil {
+			return fmt.Errorf("ip: %s", err.Error())
+		}
+	}
+	keys := make([]string, 0, len(t.Params))
+	for k := range t.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		check, ok := knownParams[k]
+		if !ok {
+			return fmt.Errorf("unknown parameter %q", k)
+		}
+		if err := check(t.Params[k]); err != nil {
+			return fmt.Errorf("%s: %s", k, err.Error())
+		}
+	}
+	if (t.Params["master_ip"] == "") != (t.Params["api_port"] == "") {
+		return fmt.Errorf("master_ip and api_port must be set together")
+	}
+	if (t.Params["client_cert"] == "") != (t.Params["client_key"] == "") {
+		return fmt.Errorf("client_cert and client_key must be set together")
+	}
+	if t.Params["bearer_token"] != "" && t.Params["bearer_token_file"] != "" {
+		return fmt.Errorf("only one of bearer_token and bearer_token_file may be set")
+	}
+	if t.Params["stats_source"] == "cadvisor" && t.Params["cadvisor_port"] == "" {
+		return fmt.Errorf("stats_source cadvisor requires cadvisor_port")
+	}
+	if t.Params["stats_source"] == "kubelet" && t.Params["node_ip"] == "" {
+		return fmt.Errorf("stats_source kubelet requires node_ip")
+	}
+	if t.Params["kube_context"] != "" && t.Params["kubeconfig"] == "" {
+		return fmt.Errorf("kube_context requires kubeconfig")
+	}
+	if t.Params["cadvisor_port"] != "" && t.Params["node_ip"] == "" {
+		return fmt.Errorf("cadvisor_port requires node_ip")
+	}
+	if t.Params["pod_name"] != "" && t.Params["pod_namespace"] == "" {
+		return fmt.Errorf("pod_name requires pod_namespace")
+	}
+	return nil
+}
+
+func checkNotEmpty(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	return nil
+}
+
+func checkHost(v string) error {
+	if net.ParseIP(v) != nil {
+		return nil
+	}
+	if v == "" || strings.ContainsAny(v, " :/") {
+		return fmt.Errorf("%q is not a valid IP address or host name", v)
+	}
+	return nil
+}
+
+func checkBool(v string) error {
+	if v != "true" && v != "false" {
+		return fmt.Errorf("%q must be true or false", v)
+	}
+	return nil
+}
+
+func checkScheme(v string) error {
+	if v != "http" && v != "https" {
+		return fmt.Errorf("%q must be http or https", v)
+	}
+	return nil
+}
+
+func checkStatsSource(v string) error {
+	if v != "cadvisor" && v != "kubelet" && v != "kubelet_proxy" {
+		return fmt.Errorf("%q must be cadvisor, kubelet or kubelet_proxy", v)
+	}
+	return nil
+}
+
+func checkSelector(v string) error {
+	if _, err := labels.Parse(v); err != nil {
+		return fmt.Errorf("%q is not a valid label selector: %s", v, err.Error())
+	}
+	return nil
+}
+
+func checkPort(v string) error {
+	port, err := strconv.Atoi(v)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("%q is not a valid port", v)
+	}
+	return nil
+}
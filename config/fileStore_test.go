@@ -0,0 +1,182 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		ip     string
+		params map[string]string
+		err    string
+	}{
+		{"master", "a", "10.0.0.1", map[string]string{"master_ip": "10.0.0.1", "api_port": "8080"}, ""},
+		{"no params", "a", "", nil, ""},
+		{"host name", "a", "node-1.example.com", nil, ""},
+		{"kubelet", "a", "", map[string]string{"stats_source": "kubelet", "node_ip": "10.0.0.2"}, ""},
+		{"cadvisor", "a", "", map[string]string{"stats_source": "cadvisor", "node_ip": "10.0.0.2", "cadvisor_port": "4194"}, ""},
+
+		{"empty id", " ", "", nil, "target id must not be empty"},
+		{"bad ip", "a", "10.0.0.1:80", nil, "ip:"},
+		{"unknown param", "a", "", map[string]string{"master_port": "8080"}, `unknown parameter "master_port"`},
+		{"bad port", "a", "", map[string]string{"master_ip": "10.0.0.1", "api_port": "80800"}, "api_port:"},
+		{"bad bool", "a", "", map[string]string{"in_cluster": "yes"}, "in_cluster:"},
+		{"bad scheme", "a", "", map[string]string{"api_scheme": "ftp"}, "api_scheme:"},
+		{"bad stats source", "a", "", map[string]string{"stats_source": "heapster"}, "stats_source:"},
+		{"bad selector", "a", "", map[string]string{"label_selector": "app in ("}, "label_selector:"},
+		{"empty value", "a", "", map[string]string{"pod_namespace": " "}, "pod_namespace:"},
+
+		{"master_ip alone", "a", "", map[string]string{"master_ip": "10.0.0.1"}, "master_ip and api_port must be set together"},
+		{"api_port alone", "a", "", map[string]string{"api_port": "8080"}, "master_ip and api_port must be set together"},
+		{"client_cert alone", "a", "", map[string]string{"client_cert": "/c.pem"}, "client_cert and client_key must be set together"},
+		{"client_key alone", "a", "", map[string]string{"client_key": "/k.pem"}, "client_cert and client_key must be set together"},
+		{"two tokens", "a", "", map[string]string{"bearer_token": "t", "bearer_token_file": "/t"}, "only one of bearer_token and bearer_token_file"},
+		{"cadvisor without port", "a", "", map[string]string{"stats_source": "cadvisor", "node_ip": "10.0.0.2"}, "stats_source cadvisor requires cadvisor_port"},
+		{"kubelet without node", "a", "", map[string]string{"stats_source": "kubelet"}, "stats_source kubelet requires node_ip"},
+		{"context without kubeconfig", "a", "", map[string]string{"kube_context": "prod"}, "kube_context requires kubeconfig"},
+		{"cadvisor_port without node", "a", "", map[string]string{"cadvisor_port": "4194"}, "cadvisor_port requires node_ip"},
+		{"pod without namespace", "a", "", map[string]string{"pod_name": "web-0"}, "pod_name requires pod_namespace"},
+	}
+	for _, tt := range tests {
+		err := validateTarget(tt.id, fileTarget{IP: tt.ip, Params: tt.params})
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%s: expected error containing %q", tt.name, tt.err)
+		case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+			t.Errorf("%s: error %q does not contain %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+const (
+	targetsV1 = `
+targets:
+  good:
+    ip: 10.0.0.1
+    params:
+      master_ip: 10.0.0.1
+      api_port: "8080"
+  typo:
+    ip: 10.0.0.2
+    params:
+      master_port: "8080"
+`
+	targetsV2 = `{"targets": {"other": {"ip": "10.0.0.3"}}}`
+)
+
+// waitFor polls the store until id resolves or not, as want says.
+func waitFor(t *testing.T, s *FileStore, id string, want bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		_, err := s.GetMonitorInfo(id)
+		if (err == nil) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("target %s present = %v, want %v", id, err == nil, want)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestFileStoreReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "targets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "targets.yaml")
+	if err := ioutil.WriteFile(path, []byte(targetsV1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %s", err)
+	}
+	defer s.Close()
+
+	info, err := s.GetMonitorInfo("good")
+	if err != nil {
+		t.Fatalf("GetMonitorInfo(good): %s", err)
+	}
+	if info.IP != "10.0.0.1" || info.Params_maps["api_port"] != "8080" {
+		t.Errorf("GetMonitorInfo(good) = %+v", info)
+	}
+	if _, err := s.GetMonitorInfo("typo"); err == nil {
+		t.Errorf("target with an unknown parameter was loaded")
+	}
+
+	// A file that does not parse keeps the previous targets.
+	if err := ioutil.WriteFile(path, []byte("targets: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(); err == nil {
+		t.Errorf("Reload of a broken file succeeded")
+	}
+	waitFor(t, s, "good", true)
+
+	if err := ioutil.WriteFile(path, []byte(targetsV2), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, s, "other", true)
+	waitFor(t, s, "good", false)
+
+	if err := s.Close(); err != nil {
+		t.Errorf("Close: %s", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close: %s", err)
+	}
+}
+
+// TestFileStoreConfigMapSwap lays the file out the way the kubelet mounts a
+// ConfigMap, with targets.yaml -> ..data/targets.yaml and ..data pointing at
+// a timestamped directory, and updates it by swapping the ..data symlink.
+func TestFileStoreConfigMapSwap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeVersion := func(name, content string) {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name, "targets.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(name, filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("..2026_10_18_10_00_00.1", targetsV1)
+	path := filepath.Join(dir, "targets.yaml")
+	if err := os.Symlink(filepath.Join("..data", "targets.yaml"), path); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %s", err)
+	}
+	defer s.Close()
+	waitFor(t, s, "good", true)
+
+	writeVersion("..2026_10_18_10_05_00.2", targetsV2)
+	os.RemoveAll(filepath.Join(dir, "..2026_10_18_10_00_00.1"))
+	waitFor(t, s, "other", true)
+	waitFor(t, s, "good", false)
+}
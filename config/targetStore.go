@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// TargetStore resolves the target UUID passed to a collector into the
+// connection information stored for it.
+type TargetStore interface {
+	GetMonitorInfo(id string) (ConnectInfoData, error)
+	Close() error
+}
+
+const (
+	StoreMySQL = "mysql"
+	StoreFile  = "file"
+)
+
+var store TargetStore
+
+// NewTargetStore builds the store selected by kind. path is only used by the
+// file store.
+func NewTargetStore(kind string, path string) (TargetStore, error) {
+	switch kind {
+	case StoreMySQL:
+		return NewMySQLStore()
+	case StoreFile:
+		return NewFileStore(path)
+	default:
+		return nil, fmt.Errorf("unknown target store %q", kind)
+	}
+}
+
+// SetTargetStore replaces the store used by GetMonitorInfo.
+func SetTargetStore(s TargetStore) {
+	store = s
+}
+
+func GetMonitorInfo(id string) ConnectInfoData {
+	if store == nil {
+		log.Printf("no target store configured")
+		return ConnectInfoData{Params_maps: map[string]string{}}
+	}
+	info, err := store.GetMonitorInfo(id)
+	if err != nil {
+		log.Printf("get monitor info of %s error: %s", id, err.Error())
+		return ConnectInfoData{Params_maps: map[string]string{}}
+	}
+	return info
+}
+
+func CloseTargetStore() {
+	if store != nil {
+		store.Close()
+	}
+}
+
+// MemoryStore keeps targets in memory. It is filled through Put, so it is
+// not selectable with --target.store; use it in tests and when embedding
+// the collectors.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	targets map[string]ConnectInfoData
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{targets: make(map[string]ConnectInfoData)}
+}
+
+func (s *MemoryStore) Put(id string, info ConnectInfoData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets[id] = info
+}
+
+func (s *MemoryStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.targets, id)
+}
+
+func (s *MemoryStore) replace(targets map[string]ConnectInfoData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = targets
+}
+
+func (s *MemoryStore) GetMonitorInfo(id string) (ConnectInfoData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.targets[id]
+	if !ok {
+		return ConnectInfoData{}, fmt.Errorf("target %s not found", id)
+	}
+	return info, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
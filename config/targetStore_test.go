@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	if _, err := s.GetMonitorInfo("a"); err == nil {
+		t.Fatalf("GetMonitorInfo on an empty store succeeded")
+	}
+
+	s.Put("a", ConnectInfoData{"10.0.0.1", map[string]string{"api_port": "8080"}})
+	info, err := s.GetMonitorInfo("a")
+	if err != nil {
+		t.Fatalf("GetMonitorInfo(a): %s", err)
+	}
+	if info.IP != "10.0.0.1" || info.Params_maps["api_port"] != "8080" {
+		t.Errorf("GetMonitorInfo(a) = %+v", info)
+	}
+
+	s.Put("a", ConnectInfoData{"10.0.0.2", map[string]string{}})
+	if info, _ := s.GetMonitorInfo("a"); info.IP != "10.0.0.2" {
+		t.Errorf("Put did not overwrite target a, got %+v", info)
+	}
+
+	s.Delete("a")
+	if _, err := s.GetMonitorInfo("a"); err == nil {
+		t.Errorf("GetMonitorInfo after Delete succeeded")
+	}
+
+	s.Put("b", ConnectInfoData{"10.0.0.3", map[string]string{}})
+	s.replace(map[string]ConnectInfoData{"c": {"10.0.0.4", map[string]string{}}})
+	if _, err := s.GetMonitorInfo("b"); err == nil {
+		t.Errorf("target b survived replace")
+	}
+	if _, err := s.GetMonitorInfo("c"); err != nil {
+		t.Errorf("GetMonitorInfo(c) after replace: %s", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("Close: %s", err)
+	}
+}
+
+func TestGetMonitorInfo(t *testing.T) {
+	defer SetTargetStore(nil)
+
+	SetTargetStore(nil)
+	if info := GetMonitorInfo("a"); info.Params_maps == nil {
+		t.Errorf("GetMonitorInfo without a store returned nil params")
+	}
+
+	s := NewMemoryStore()
+	s.Put("a", ConnectInfoData{"10.0.0.1", map[string]string{"node_ip": "10.0.0.1"}})
+	SetTargetStore(s)
+	if info := GetMonitorInfo("a"); info.Params_maps["node_ip"] != "10.0.0.1" {
+		t.Errorf("GetMonitorInfo(a) = %+v", info)
+	}
+	if info := GetMonitorInfo("missing"); info.Params_maps == nil || len(info.Params_maps) != 0 {
+		t.Errorf("GetMonitorInfo(missing) = %+v, want empty params", info)
+	}
+}
+
+func TestNewTargetStore(t *testing.T) {
+	if _, err := NewTargetStore("memory", ""); err == nil {
+		t.Errorf("NewTargetStore(memory) succeeded, an empty memory store can never be filled")
+	}
+	if _, err := NewTargetStore(StoreFile, ""); err == nil {
+		t.Errorf("NewTargetStore(file) without a path succeeded")
+	}
+	if _, err := NewTargetStore("etcd", ""); err == nil {
+		t.Errorf("NewTargetStore(etcd) succeeded")
+	}
+}
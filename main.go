@@ -11,10 +11,30 @@ import (
 	"container-exporter/config"
 	"github.com/gorilla/mux"
 	"container-exporter/collectors/api"
+	"container-exporter/collectors/kube"
 	"log"
 )
 var listenAddress = kingpin.Flag("web.listen-address","Address to listen on for web " +
 	"interface and telemetry.").Default(":9109").String()
+var targetStore = kingpin.Flag("target.store","Where target connection info is read from " +
+	"(mysql or file).").Default(config.StoreMySQL).Enum(config.StoreMySQL,config.StoreFile)
+var targetFile = kingpin.Flag("target.file","Path of the YAML or JSON targets file used by the file store. " +
+	"It is reloaded on change or SIGHUP.").Default("").String()
+var cacheIdleTimeout = kingpin.Flag("cache.idle-timeout","Stop the informer cache of a cluster " +
+	"after it has not been scraped for this long.").Default("10m").Duration()
+var cacheSyncTimeout = kingpin.Flag("cache.sync-timeout","How long a scrape waits for the " +
+	"informer cache of a cluster to sync.").Default("30s").Duration()
+var warningWindow = kingpin.Flag("events.warning-window","How far back /k8sc and /k8sn report " +
+	"warning events of their pod or node.").Default("1h").Duration()
+var nodeLabels = kingpin.Flag("node.labels","Comma separated node label keys exported by /k8sn in " +
+	"k8s_node_labels. The first one also fills the nodelabel label.").Default("node").String()
+var serviceProbeTimeout = kingpin.Flag("service.probe-timeout","Timeout of the TCP connect check " +
+	"/k8ss runs against each ready endpoint when asked to probe.").Default("1s").Duration()
+var serviceProbeBudget = kingpin.Flag("service.probe-budget","How long all TCP connect checks of a " +
+	"/k8ss scrape may take together. Keep it below the scrape timeout.").Default("5s").Duration()
+var volumeSummaryTimeout = kingpin.Flag("volume.summary-timeout","Timeout of the kubelet summary fetch " +
+	"/k8sv runs against each node mounting claims.").Default("10s").Duration()
+var procPath = kingpin.Flag("path.procfs","Where the host procfs read by /host and /k8sipvs is mounted.").Default("/proc").String()
 
 
 
@@ -28,19 +48,45 @@ func runCollector(collector prometheus.Collector,w http.ResponseWriter,r *http.R
 	h:=promhttp.HandlerFor(gatherers,promhttp.HandlerOpts{})
 	h.ServeHTTP(w,r)
 }
-func init() {
-	config.GetDBHandle()
-}
 func main() {
 	kingpin.Parse()
+	store,err := config.NewTargetStore(*targetStore,*targetFile)
+	if err!=nil {
+		log.Fatalf("create target store error: %s",err.Error())
+	}
+	config.SetTargetStore(store)
+	defer config.CloseTargetStore()
+	kube.Caches.SetTimeouts(*cacheIdleTimeout,*cacheSyncTimeout)
+	collectors.RecentWarningWindow = *warningWindow
+	collectors.NodeLabels = strings.Split(*nodeLabels,",")
+	collectors.ServiceProbeTimeout = *serviceProbeTimeout
+	collectors.ServiceProbeBudget = *serviceProbeBudget
+	collectors.VolumeSummaryTimeout = *volumeSummaryTimeout
+	prometheus.MustRegister(kube.Caches)
+	defer kube.Caches.Stop()
 	r := mux.NewRouter()
 	r.HandleFunc("/k8s",handler)
 	r.HandleFunc("/k8sc",handler)
 	r.HandleFunc("/k8sn",handler)
+	r.HandleFunc("/k8sp",handler)
+	r.HandleFunc("/k8snc",handler)
+	r.HandleFunc("/k8sw",handler)
+	r.HandleFunc("/k8se",handler)
+	r.HandleFunc("/k8sv",handler)
+	r.HandleFunc("/k8ss",handler)
+	r.HandleFunc("/k8sj",handler)
+	r.HandleFunc("/k8sh",handler)
+	r.HandleFunc("/k8sq",handler)
+	r.HandleFunc("/k8sipvs",handler)
+	r.HandleFunc("/host",hostHandler)
 	r.HandleFunc("/api/v1/resources",api.GetContainerList)
 	http.ListenAndServe(*listenAddress,r)
 
 }
+// hostHandler serves the node the exporter runs on, so it takes no target.
+func hostHandler(w http.ResponseWriter,r *http.Request)  {
+	runCollector(collectors.HostCollector{*procPath},w,r)
+}
 func handler(w http.ResponseWriter,r *http.Request)  {
 	var collectorType prometheus.Collector
 	target:= r.URL.Query().Get("target")
@@ -60,6 +106,36 @@ func handler(w http.ResponseWriter,r *http.Request)  {
 	case "/k8sn":
 		collectorType = collectors.K8sNodeCollector{target}
 		break
+	case "/k8sp":
+		collectorType = collectors.K8sPodCollector{target}
+		break
+	case "/k8snc":
+		collectorType = collectors.K8sNodeContainerCollector{target}
+		break
+	case "/k8sw":
+		collectorType = collectors.K8sWorkloadCollector{target,r.URL.Query().Get("namespace"),r.URL.Query().Get("selector")}
+		break
+	case "/k8se":
+		collectorType = collectors.K8sEventCollector{target}
+		break
+	case "/k8sv":
+		collectorType = collectors.K8sVolumeCollector{target}
+		break
+	case "/k8ss":
+		collectorType = collectors.K8sServiceCollector{target,r.URL.Query().Get("namespace"),r.URL.Query().Get("selector"),r.URL.Query().Get("probe")}
+		break
+	case "/k8sj":
+		collectorType = collectors.K8sJobCollector{target,r.URL.Query().Get("namespace"),r.URL.Query().Get("selector")}
+		break
+	case "/k8sh":
+		collectorType = collectors.K8sHPACollector{target,r.URL.Query().Get("namespace"),r.URL.Query().Get("selector")}
+		break
+	case "/k8sq":
+		collectorType = collectors.K8sQuotaCollector{target,r.URL.Query().Get("namespace")}
+		break
+	case "/k8sipvs":
+		collectorType = collectors.K8sIPVSCollector{target,*procPath}
+		break
 	default:
 		break
 	}